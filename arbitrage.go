@@ -0,0 +1,378 @@
+package exchange_models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrUnhedgedLeg = errors.New("second leg failed and first leg is not fully cancelled")
+
+// UnhedgedLegError первая нога осталась открытой или успела исполниться на Filled.
+// errors.Is(err, ErrUnhedgedLeg) верно. Если исполнение узнать не удалось,
+// Err содержит ErrFilledUnknown, и Filled не заполнен
+type UnhedgedLegError struct {
+	Venue   ExchangeName
+	OrderId string
+	Filled  float64 // исполненный объем первой ноги, если отмена удалась
+	Err     error
+}
+
+func (e *UnhedgedLegError) Error() string {
+	return fmt.Sprintf("%s: %s order %s filled %f: %s", ErrUnhedgedLeg, e.Venue, e.OrderId, e.Filled, e.Err)
+}
+
+func (e *UnhedgedLegError) Is(target error) bool {
+	return target == ErrUnhedgedLeg
+}
+
+func (e *UnhedgedLegError) Unwrap() error {
+	return e.Err
+}
+
+// Venue пара на конкретной бирже. Base и Quote в обозначениях биржи,
+// сравниваются пары с одинаковым NormalizeSymbol
+type Venue struct {
+	Name           ExchangeName
+	Connector      Connector
+	Base           string
+	Quote          string
+	BasePrecision  int
+	PricePrecision int
+	TakerFee       float64 // доля от объема сделки, 0.002 = 0.2%
+}
+
+func (v *Venue) Symbol() string {
+	return NormalizeSymbol(v.Base, v.Quote)
+}
+
+// NormalizeSymbol общее обозначение пары для всех бирж: BASE_QUOTE в верхнем регистре
+func NormalizeSymbol(base, quote string) string {
+	return symbol(strings.ToUpper(strings.TrimSpace(base)), strings.ToUpper(strings.TrimSpace(quote)))
+}
+
+// ParseSymbol разбирает пару вида btc_usdt, BTC-USDT или BTC/USDT
+func ParseSymbol(s string) (base, quote string, err error) {
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '_' || r == '-' || r == '/'
+	})
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("can not parse symbol %q", s)
+	}
+	return strings.ToUpper(parts[0]), strings.ToUpper(parts[1]), nil
+}
+
+// VenueQuote лучшие цены пары на бирже вместе со стаканом
+type VenueQuote struct {
+	Venue *Venue
+	Book  *OrderBookSnapshot
+	Time  time.Time
+}
+
+func (q *VenueQuote) BestBid() float64 {
+	if best, ok := q.Book.Best(Buy); ok {
+		return best.Price
+	}
+	return 0
+}
+
+func (q *VenueQuote) BestAsk() float64 {
+	if best, ok := q.Book.Best(Sell); ok {
+		return best.Price
+	}
+	return 0
+}
+
+// ArbitrageSignal покупка на одной бирже и продажа на другой с прибылью после комиссий
+type ArbitrageSignal struct {
+	Symbol    string
+	Buy       *Venue
+	Sell      *Venue
+	BuyPrice  float64 // худшая цена покупки, по ней выставляется ордер
+	SellPrice float64 // худшая цена продажи
+	Amount    float64
+	Profit    float64 // в quote после комиссий
+	ProfitBps float64 // прибыль относительно стоимости покупки
+	Time      time.Time
+}
+
+func (s *ArbitrageSignal) Print() {
+	fmt.Printf("%s: buy %f on %s at %f, sell on %s at %f, profit %f (%.1f bps)\n",
+		s.Symbol, s.Amount, s.Buy.Name, s.BuyPrice, s.Sell.Name, s.SellPrice, s.Profit, s.ProfitBps)
+}
+
+type ArbitrageConfig struct {
+	Venues        []*Venue
+	DepthLimit    int64   // уровней стакана на сторону, по умолчанию 20
+	MinProfitBps  float64 // сигналы с меньшей прибылью пропускаются
+	MaxBaseAmount float64 // 0 - без ограничения
+	UseBalances   bool    // ограничивать объем доступными балансами CurrencyBalance
+	Execute       bool    // Run сам исполняет найденные сигналы
+	Interval      time.Duration
+	OnError       func(err error) // ошибки опроса в Run, по умолчанию пропускаются
+}
+
+// ArbitrageMonitor опрашивает стаканы одной пары на нескольких биржах и ищет
+// возможность купить на одной бирже дешевле, чем продать на другой
+type ArbitrageMonitor struct {
+	config *ArbitrageConfig
+
+	mu     sync.Mutex
+	quotes map[ExchangeName]*VenueQuote
+}
+
+func NewArbitrageMonitor(config *ArbitrageConfig) (*ArbitrageMonitor, error) {
+	if len(config.Venues) < 2 {
+		return nil, fmt.Errorf("arbitrage needs at least 2 venues, got %d", len(config.Venues))
+	}
+	names := make(map[ExchangeName]bool, len(config.Venues))
+	for _, venue := range config.Venues {
+		if names[venue.Name] {
+			return nil, fmt.Errorf("venue %s is set twice", venue.Name)
+		}
+		names[venue.Name] = true
+	}
+	config = configCopy(config)
+	if config.DepthLimit <= 0 {
+		config.DepthLimit = 20
+	}
+	if config.Interval <= 0 {
+		config.Interval = 5 * time.Second
+	}
+	return &ArbitrageMonitor{config: config, quotes: make(map[ExchangeName]*VenueQuote)}, nil
+}
+
+// Quotes последние полученные цены по биржам
+func (m *ArbitrageMonitor) Quotes() []*VenueQuote {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]*VenueQuote, 0, len(m.quotes))
+	for _, venue := range m.config.Venues {
+		if quote, ok := m.quotes[venue.Name]; ok {
+			res = append(res, quote)
+		}
+	}
+	return res
+}
+
+// Run опрашивает биржи раз в Interval до отмены ctx и передает сигналы в handle
+func (m *ArbitrageMonitor) Run(ctx context.Context, handle func(signal *ArbitrageSignal)) error {
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+	for {
+		signals, err := m.Scan()
+		if err != nil && m.config.OnError != nil {
+			m.config.OnError(err)
+		}
+		for _, signal := range signals {
+			if m.config.Execute {
+				if err = m.Execute(signal); err != nil {
+					if m.config.OnError != nil {
+						m.config.OnError(err)
+					}
+					continue
+				}
+			}
+			handle(signal)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan опрашивает все биржи и возвращает сигналы по парам бирж с одной парой.
+// Ошибки отдельных бирж возвращаются вместе с сигналами по остальным
+func (m *ArbitrageMonitor) Scan() ([]*ArbitrageSignal, error) {
+	quotes := make([]*VenueQuote, len(m.config.Venues))
+	errs := make([]error, len(m.config.Venues))
+	var wg sync.WaitGroup
+	for idx, venue := range m.config.Venues {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			book, err := venue.Connector.Depth(venue.Base, venue.Quote, venue.BasePrecision, venue.PricePrecision, m.config.DepthLimit)
+			if err != nil {
+				errs[idx] = fmt.Errorf("%s depth: %w", venue.Name, err)
+				return
+			}
+			quotes[idx] = &VenueQuote{Venue: venue, Book: book, Time: time.Now().UTC()}
+		}()
+	}
+	wg.Wait()
+	m.mu.Lock()
+	for _, quote := range quotes {
+		if quote != nil {
+			m.quotes[quote.Venue.Name] = quote
+		}
+	}
+	m.mu.Unlock()
+
+	signals := make([]*ArbitrageSignal, 0)
+	for _, buy := range quotes {
+		for _, sell := range quotes {
+			if buy == nil || sell == nil || buy == sell || buy.Venue.Symbol() != sell.Venue.Symbol() {
+				continue
+			}
+			signal, err := m.opportunity(buy, sell)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if signal != nil && signal.ProfitBps >= m.config.MinProfitBps {
+				signals = append(signals, signal)
+			}
+		}
+	}
+	return signals, errors.Join(errs...)
+}
+
+// opportunity проходит по продажам стакана buy и покупкам стакана sell, пока покупка
+// с комиссией дешевле продажи с комиссией. nil, если прибыльного объема нет
+func (m *ArbitrageMonitor) opportunity(buy, sell *VenueQuote) (*ArbitrageSignal, error) {
+	limit, err := m.amountLimit(buy.Venue, sell.Venue, buy.BestAsk())
+	if err != nil {
+		return nil, err
+	}
+	asks, bids := buy.Book.Asks, sell.Book.Bids
+	signal := &ArbitrageSignal{Symbol: buy.Venue.Symbol(), Buy: buy.Venue, Sell: sell.Venue, Time: time.Now().UTC()}
+	// шаги прохода по стаканам, прибыль считается по ним после округления объема
+	type step struct {
+		amount, ask, bid float64
+	}
+	steps := make([]step, 0)
+	var total float64
+	var askIdx, bidIdx int
+	var askUsed, bidUsed float64
+	for askIdx < len(asks) && bidIdx < len(bids) && limit > 0 {
+		ask, bid := asks[askIdx], bids[bidIdx]
+		if ask.Price*(1+buy.Venue.TakerFee) >= bid.Price*(1-sell.Venue.TakerFee) {
+			break
+		}
+		amount := math.Min(math.Min(ask.Amount-askUsed, bid.Amount-bidUsed), limit)
+		steps = append(steps, step{amount: amount, ask: ask.Price, bid: bid.Price})
+		total += amount
+		limit -= amount
+		askUsed += amount
+		bidUsed += amount
+		if askUsed >= ask.Amount {
+			askIdx, askUsed = askIdx+1, 0
+		}
+		if bidUsed >= bid.Amount {
+			bidIdx, bidUsed = bidIdx+1, 0
+		}
+	}
+	precision := min(buy.Venue.BasePrecision, sell.Venue.BasePrecision)
+	signal.Amount = Floor(total, precision)
+	if signal.Amount <= 0 {
+		return nil, nil
+	}
+	var cost float64
+	left := signal.Amount
+	for _, st := range steps {
+		if left <= 0 {
+			break
+		}
+		amount := math.Min(st.amount, left)
+		signal.Profit += amount * (st.bid*(1-sell.Venue.TakerFee) - st.ask*(1+buy.Venue.TakerFee))
+		cost += amount * st.ask
+		signal.BuyPrice, signal.SellPrice = st.ask, st.bid
+		left -= amount
+	}
+	signal.ProfitBps = signal.Profit / cost * 10000
+	return signal, nil
+}
+
+// amountLimit максимальный объем в base с учетом MaxBaseAmount и балансов:
+// на бирже покупки нужен quote, на бирже продажи нужен base
+func (m *ArbitrageMonitor) amountLimit(buy, sell *Venue, price float64) (float64, error) {
+	limit := math.Inf(1)
+	if m.config.MaxBaseAmount > 0 {
+		limit = m.config.MaxBaseAmount
+	}
+	if !m.config.UseBalances || price <= 0 {
+		return limit, nil
+	}
+	quote, _, err := buy.Connector.CurrencyBalance(buy.Quote)
+	if err != nil {
+		return 0, fmt.Errorf("%s %s balance: %w", buy.Name, buy.Quote, err)
+	}
+	base, _, err := sell.Connector.CurrencyBalance(sell.Base)
+	if err != nil {
+		return 0, fmt.Errorf("%s %s balance: %w", sell.Name, sell.Base, err)
+	}
+	return math.Min(limit, math.Min(quote/(price*(1+buy.TakerFee)), base)), nil
+}
+
+// Execute выставляет покупку и продажу по худшим ценам сигнала. Если вторая нога не выставилась,
+// первая отменяется. Если отмена не удалась или покупка успела частично исполниться,
+// возвращается UnhedgedLegError с исполненным объемом. Исполнение отмененной покупки
+// берется из OrderLookup, без него - из прироста баланса base на бирже покупки
+// (комиссия в base и сторонние сделки по тому же балансу его искажают)
+func (m *ArbitrageMonitor) Execute(signal *ArbitrageSignal) error {
+	buy, sell := signal.Buy, signal.Sell
+	var baseBefore float64
+	var balanceErr error
+	if _, ok := buy.Connector.(OrderLookup); !ok {
+		baseBefore, balanceErr = totalBalance(buy.Connector, buy.Base)
+	}
+	posted := time.Now().UTC()
+	buyId, err := buy.Connector.PostLimitOrder(buy.Base, buy.Quote, Buy, signal.Amount, signal.BuyPrice, buy.BasePrecision, buy.PricePrecision)
+	if err != nil {
+		return fmt.Errorf("%s buy leg: %w", buy.Name, err)
+	}
+	_, err = sell.Connector.PostLimitOrder(sell.Base, sell.Quote, Sell, signal.Amount, signal.SellPrice, sell.BasePrecision, sell.PricePrecision)
+	if err == nil {
+		return nil
+	}
+	legErr := fmt.Errorf("%s sell leg: %w", sell.Name, err)
+	if cancelErr := buy.Connector.CancelOrder(buyId, buy.Base, buy.Quote); cancelErr != nil {
+		return &UnhedgedLegError{Venue: buy.Name, OrderId: buyId, Err: errors.Join(legErr, cancelErr)}
+	}
+	// до отмены покупка могла частично исполниться
+	order := newNetOrder(&NetOrderConfig{
+		ExName:       buy.Name,
+		Symbol:       symbol(buy.Base, buy.Quote),
+		Id:           buyId,
+		Side:         Buy,
+		OrderType:    Limit,
+		Price:        Round(signal.BuyPrice, buy.PricePrecision),
+		BaseAmount:   Round(signal.Amount, buy.BasePrecision),
+		CreationDate: posted,
+		BasePrec:     buy.BasePrecision,
+		PricePrec:    buy.PricePrecision,
+	})
+	final, err := FinalFilled(buy.Connector, buy.Base, buy.Quote, []*NetOrder{order})
+	filled := final[buyId]
+	if errors.Is(err, ErrFilledUnknown) {
+		err = errors.Join(err, balanceErr)
+		if balanceErr == nil {
+			var baseAfter float64
+			if baseAfter, err = totalBalance(buy.Connector, buy.Base); err == nil {
+				filled = Round(math.Max(0, baseAfter-baseBefore), buy.BasePrecision)
+			}
+		}
+	}
+	if err != nil {
+		return &UnhedgedLegError{Venue: buy.Name, OrderId: buyId, Err: errors.Join(legErr, fmt.Errorf("buy leg filled amount: %w", err))}
+	}
+	if filled > 0 {
+		return &UnhedgedLegError{Venue: buy.Name, OrderId: buyId, Filled: filled, Err: legErr}
+	}
+	return fmt.Errorf("%w, buy leg cancelled", legErr)
+}
+
+// totalBalance свободный и замороженный баланс валюты
+func totalBalance(c Connector, currency string) (float64, error) {
+	available, freeze, err := c.CurrencyBalance(currency)
+	if err != nil {
+		return 0, fmt.Errorf("%s balance: %w", currency, err)
+	}
+	return available + freeze, nil
+}
@@ -0,0 +1,187 @@
+package exchange_models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingPostConnector struct {
+	*mockConnector
+}
+
+func (c *failingPostConnector) PostLimitOrder(base, quote string, side Side, baseAmount, price float64, basePrecision, pricePrecision int) (string, error) {
+	return "", errors.New("insufficient funds")
+}
+
+func newTestVenues() (*mockConnector, *mockConnector, []*Venue) {
+	p2b, az := newMockConnector(), newMockConnector()
+	p2b.sellBook = []*NetOrder{mockBookOrder(Sell, 100, 1), mockBookOrder(Sell, 100.5, 2), mockBookOrder(Sell, 102, 5)}
+	p2b.buyBook = []*NetOrder{mockBookOrder(Buy, 99, 1)}
+	az.buyBook = []*NetOrder{mockBookOrder(Buy, 101.5, 2), mockBookOrder(Buy, 101, 2), mockBookOrder(Buy, 98, 5)}
+	az.sellBook = []*NetOrder{mockBookOrder(Sell, 103, 1)}
+	return p2b, az, []*Venue{
+		{Name: P2PB2B, Connector: p2b, Base: "sdfa", Quote: "usdt", BasePrecision: 3, PricePrecision: 2, TakerFee: 0.002},
+		{Name: AzBit, Connector: az, Base: "SDFA", Quote: "USDT", BasePrecision: 3, PricePrecision: 2, TakerFee: 0.001},
+	}
+}
+
+func TestParseSymbol(t *testing.T) {
+	for _, s := range []string{"sdfa_usdt", "SDFA-USDT", "Sdfa/Usdt"} {
+		base, quote, err := ParseSymbol(s)
+		assert.NoError(t, err)
+		assert.Equal(t, "SDFA_USDT", NormalizeSymbol(base, quote))
+	}
+	_, _, err := ParseSymbol("SDFAUSDT")
+	assert.Error(t, err)
+}
+
+func TestArbitrageMonitor_Scan(t *testing.T) {
+	_, az, venues := newTestVenues()
+	monitor, err := NewArbitrageMonitor(&ArbitrageConfig{Venues: venues})
+	assert.NoError(t, err)
+	signals, err := monitor.Scan()
+	assert.NoError(t, err)
+	assert.Len(t, signals, 1)
+	signal := signals[0]
+	assert.Equal(t, P2PB2B, signal.Buy.Name)
+	assert.Equal(t, AzBit, signal.Sell.Name)
+	// 1 по 100 и 2 по 100.5 продаются по 101.5 и 101, продажа по 102 уже невыгодна
+	assert.Equal(t, 3.0, signal.Amount)
+	assert.Equal(t, 100.5, signal.BuyPrice)
+	assert.Equal(t, 101.0, signal.SellPrice)
+	assert.Greater(t, signal.Profit, 0.0)
+	assert.Len(t, monitor.Quotes(), 2)
+
+	// баланс base на бирже продажи ограничивает объем
+	az.balances["SDFA"] = 1.5
+	venues[0].Connector.(*mockConnector).balances["usdt"] = 1000
+	monitor.config.UseBalances = true
+	signals, err = monitor.Scan()
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, signals[0].Amount)
+
+	monitor.config.MinProfitBps = 1000
+	signals, err = monitor.Scan()
+	assert.NoError(t, err)
+	assert.Empty(t, signals)
+}
+
+func TestArbitrageMonitor_Execute(t *testing.T) {
+	p2b, az, venues := newTestVenues()
+	monitor, err := NewArbitrageMonitor(&ArbitrageConfig{Venues: venues, Execute: true, Interval: time.Millisecond})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	executed := make([]*ArbitrageSignal, 0)
+	assert.NoError(t, monitor.Run(ctx, func(signal *ArbitrageSignal) {
+		executed = append(executed, signal)
+		cancel()
+	}))
+	assert.Len(t, executed, 1)
+	assert.Len(t, p2b.openOrders, 1)
+	assert.Len(t, az.openOrders, 1)
+	assert.Equal(t, Sell, az.openOrders[0].Side())
+
+	// вторая нога не выставилась, первая отменяется без исполнения
+	signal := executed[0]
+	signal.Buy = &Venue{Name: P2PB2B, Connector: &lookupConnector{p2b}, Base: "SDFA", Quote: "USDT", BasePrecision: 3, PricePrecision: 2}
+	signal.Sell = &Venue{Name: AzBit, Connector: &failingPostConnector{az}, Base: "SDFA", Quote: "USDT", BasePrecision: 3, PricePrecision: 2}
+	err = monitor.Execute(signal)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrUnhedgedLeg))
+	assert.Len(t, p2b.openOrders, 1)
+	assert.Len(t, p2b.cancelled, 1)
+
+	// первая нога успела исполниться и не отменяется
+	signal.Buy = &Venue{Name: P2PB2B, Connector: &fillingConnector{mockConnector: p2b, fillRatio: 1}, Base: "SDFA", Quote: "USDT", BasePrecision: 3, PricePrecision: 2}
+	err = monitor.Execute(signal)
+	assert.ErrorIs(t, err, ErrUnhedgedLeg)
+	var unhedged *UnhedgedLegError
+	assert.ErrorAs(t, err, &unhedged)
+	assert.Equal(t, 0.0, unhedged.Filled)
+
+	// покупка отменилась, но до отмены исполнилась наполовину
+	signal.Buy.Connector = &fillingConnector{mockConnector: p2b, fillRatio: 0.5}
+	err = monitor.Execute(signal)
+	assert.ErrorIs(t, err, ErrUnhedgedLeg)
+	assert.ErrorAs(t, err, &unhedged)
+	assert.Equal(t, Floor(signal.Amount*0.5, 3), unhedged.Filled)
+	assert.Equal(t, P2PB2B, unhedged.Venue)
+}
+
+// cancelFillConnector к моменту отмены ордера успевает купить fill base
+type cancelFillConnector struct {
+	*mockConnector
+	fill       float64
+	balanceErr error
+}
+
+func (c *cancelFillConnector) CancelOrder(orderId, base, quote string) error {
+	c.mu.Lock()
+	c.balances[base] += c.fill
+	c.mu.Unlock()
+	return c.mockConnector.CancelOrder(orderId, base, quote)
+}
+
+func (c *cancelFillConnector) CurrencyBalance(currency string) (available, freeze float64, err error) {
+	if c.balanceErr != nil {
+		return 0, 0, c.balanceErr
+	}
+	return c.mockConnector.CurrencyBalance(currency)
+}
+
+func TestArbitrageMonitor_ExecuteByBalance(t *testing.T) {
+	p2b, az, venues := newTestVenues()
+	monitor, err := NewArbitrageMonitor(&ArbitrageConfig{Venues: venues})
+	assert.NoError(t, err)
+	signals, err := monitor.Scan()
+	assert.NoError(t, err)
+	signal := signals[0]
+	signal.Sell = &Venue{Name: AzBit, Connector: &failingPostConnector{az}, Base: "SDFA", Quote: "USDT", BasePrecision: 3, PricePrecision: 2}
+	buy := &cancelFillConnector{mockConnector: p2b}
+	signal.Buy = &Venue{Name: P2PB2B, Connector: buy, Base: "sdfa", Quote: "usdt", BasePrecision: 3, PricePrecision: 2}
+
+	// баланс base не вырос: покупка отменена без исполнения
+	err = monitor.Execute(signal)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrUnhedgedLeg))
+
+	// баланс base вырос на исполненную часть покупки
+	p2b.balances["sdfa"] = 2
+	buy.fill = 0.3
+	err = monitor.Execute(signal)
+	var unhedged *UnhedgedLegError
+	assert.ErrorAs(t, err, &unhedged)
+	assert.Equal(t, 0.3, unhedged.Filled)
+
+	// ни OrderLookup, ни баланса: исполнение неизвестно
+	buy.balanceErr = errors.New("balance is unavailable")
+	err = monitor.Execute(signal)
+	assert.ErrorIs(t, err, ErrUnhedgedLeg)
+	assert.ErrorIs(t, err, ErrFilledUnknown)
+	assert.ErrorAs(t, err, &unhedged)
+	assert.Zero(t, unhedged.Filled)
+}
+
+func TestArbitrageMonitor_ProfitOnFlooredAmount(t *testing.T) {
+	p2b, az, venues := newTestVenues()
+	p2b.sellBook = []*NetOrder{mockBookOrder(Sell, 100, 1.25), mockBookOrder(Sell, 100.1, 0.04)}
+	az.buyBook = []*NetOrder{mockBookOrder(Buy, 101.5, 5)}
+	venues[1].BasePrecision = 1
+	monitor, err := NewArbitrageMonitor(&ArbitrageConfig{Venues: venues})
+	assert.NoError(t, err)
+	signals, err := monitor.Scan()
+	assert.NoError(t, err)
+	assert.Len(t, signals, 1)
+	signal := signals[0]
+	// 1.29 округляется до 1.2, уровень 100.1 в сделку не попадает
+	assert.Equal(t, 1.2, signal.Amount)
+	assert.Equal(t, 100.0, signal.BuyPrice)
+	profit := 1.2 * (101.5*(1-0.001) - 100*(1+0.002))
+	assert.InDelta(t, profit, signal.Profit, 1e-9)
+	assert.InDelta(t, profit/(1.2*100)*10000, signal.ProfitBps, 1e-6)
+}
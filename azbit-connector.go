@@ -1,15 +1,40 @@
 package exchange_models
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	azbitgosdk "github.com/sutapurachina/azbit-go-sdk"
-	"math"
+	"net/http"
+	"net/url"
 	"time"
 )
 
+const (
+	azBitTimeLayout = "2006-01-02T15:04:05"
+	AzBitApiURL     = "https://data.azbit.com"
+)
+
+var azBitHttpClient = &http.Client{Timeout: 10 * time.Second}
+
+var _ OrderLookup = (*AzBitConnector)(nil)
+
 type AzBitConnector struct {
 	Connector
 	Client *azbitgosdk.AzBitClient
+	ApiURL string // публичный REST API для эндпоинтов, которых нет в SDK, по умолчанию AzBitApiURL
+}
+
+// azBitTicker ответ /api/tickers
+type azBitTicker struct {
+	CurrencyPairCode string  `json:"currencyPairCode"`
+	Price            float64 `json:"price"`
+	PriceChange24h   float64 `json:"priceChange24h"` // в процентах
+	Volume24h        float64 `json:"volume24h"`
+	BidPrice         float64 `json:"bidPrice"`
+	AskPrice         float64 `json:"askPrice"`
+	High24h          float64 `json:"high24h"`
+	Low24h           float64 `json:"low24h"`
 }
 
 func NewAzBitConnector(publicKey, secretKey string) (*AzBitConnector, error) {
@@ -17,6 +42,7 @@ func NewAzBitConnector(publicKey, secretKey string) (*AzBitConnector, error) {
 
 	return &AzBitConnector{
 		Client: client,
+		ApiURL: AzBitApiURL,
 	}, nil
 }
 
@@ -42,40 +68,55 @@ func (c *AzBitConnector) OpenOrders(base, quote string, basePrecision, pricePrec
 		return nil, err
 	}
 	res := make([]*NetOrder, 0, 1)
-	for _, unexecutedOrder := range orders {
-		side := Sell
-		if unexecutedOrder.IsBid {
-			side = Buy
-		}
-		price := unexecutedOrder.Price
-		amount := unexecutedOrder.InitialAmount
-		left := unexecutedOrder.Amount
-		status := New
-		if left > 0 {
-			status = PartiallyFilled
-		}
-		orderConfig := &NetOrderConfig{
-			Id:           unexecutedOrder.ID,
-			ExName:       AzBit,
-			Symbol:       symbol(base, quote),
-			OrderType:    Limit,
-			Side:         side,
-			Status:       status,
-			Price:        price,
-			BaseAmount:   amount,
-			BasePrec:     basePrecision,
-			PricePrec:    pricePrecision,
-			FilledAmount: Round(amount-left, basePrecision),
-		}
-		order, err := NewNetOrder(orderConfig)
-		if err != nil {
-			return nil, err
-		}
-		res = append(res, order)
+	for _, order := range orders {
+		res = append(res, azBitOrder(order, base, quote, basePrecision, pricePrecision))
 	}
 	return res, err
 }
 
+// OrderByID ищет ордер среди всех ордеров пары, в том числе исполненных и снятых.
+// Снят ли ордер, MyOrders не сообщает, поэтому статус выставляется по исполнению
+func (c *AzBitConnector) OrderByID(orderId, base, quote string, basePrecision, pricePrecision int) (*NetOrder, error) {
+	orders, err := c.Client.MyOrders(base, quote, "all")
+	if err != nil {
+		return nil, err
+	}
+	for _, order := range orders {
+		if order.ID == orderId {
+			return azBitOrder(order, base, quote, basePrecision, pricePrecision), nil
+		}
+	}
+	return nil, fmt.Errorf("order %s not found", orderId)
+}
+
+func azBitOrder(order azbitgosdk.Order, base, quote string, basePrecision, pricePrecision int) *NetOrder {
+	side := Sell
+	if order.IsBid {
+		side = Buy
+	}
+	amount, left := order.InitialAmount, order.Amount
+	status := New
+	switch {
+	case left <= 0:
+		status = Filled
+	case left < amount:
+		status = PartiallyFilled
+	}
+	return newNetOrder(&NetOrderConfig{
+		Id:           order.ID,
+		ExName:       AzBit,
+		Symbol:       symbol(base, quote),
+		OrderType:    Limit,
+		Side:         side,
+		Status:       status,
+		Price:        order.Price,
+		BaseAmount:   amount,
+		BasePrec:     basePrecision,
+		PricePrec:    pricePrecision,
+		FilledAmount: Round(amount-left, basePrecision),
+	})
+}
+
 func (c *AzBitConnector) OrderBook(base, quote string, side Side, basePrecision, pricePrecision int, offset, limit int64) ([]*NetOrder, error) {
 	resp, err := c.Client.OrderBook(base, quote)
 	if err != nil {
@@ -101,7 +142,7 @@ func (c *AzBitConnector) OrderBook(base, quote string, side Side, basePrecision,
 		amount := order.Amount
 		left := order.Amount
 		status := New
-		if left > 0 {
+		if left < amount {
 			status = PartiallyFilled
 		}
 		orderConfig := &NetOrderConfig{
@@ -126,41 +167,47 @@ func (c *AzBitConnector) OrderBook(base, quote string, side Side, basePrecision,
 	return orders, nil
 }
 
+// FullOrderBook AzBit отдает стакан целиком и не поддерживает offset, поэтому листать страницы нельзя
 func (c *AzBitConnector) FullOrderBook(base, quote string, side Side, basePrecision, pricePrecision int) ([]*NetOrder, error) {
-	var offset int64 = 0
-	res := make([]*NetOrder, 0, 1)
-	orders, err := c.OrderBook(base, quote, side, basePrecision, pricePrecision, offset, 100)
+	return c.OrderBook(base, quote, side, basePrecision, pricePrecision, 0, 0)
+}
+
+func (c *AzBitConnector) Depth(base, quote string, basePrecision, pricePrecision int, limit int64) (*OrderBookSnapshot, error) {
+	resp, err := c.Client.OrderBook(base, quote)
 	if err != nil {
 		return nil, err
 	}
-	res = append(res, orders...)
-	for orders != nil && len(orders) > 0 {
-		offset += 100
-		orders, err = c.OrderBook(base, quote, side, basePrecision, pricePrecision, offset, 100)
-		if err != nil {
-			return nil, err
+	now := time.Now().UTC()
+	bids := newBookLevels(Buy, basePrecision, pricePrecision, now)
+	asks := newBookLevels(Sell, basePrecision, pricePrecision, now)
+	for _, level := range resp {
+		if level.IsBid {
+			bids.addLevel(level.Price, level.Amount)
+		} else {
+			asks.addLevel(level.Price, level.Amount)
 		}
-		res = append(res, orders...)
 	}
-	return res, nil
+	return &OrderBookSnapshot{
+		Symbol: symbol(base, quote),
+		Bids:   bids.sorted(limit),
+		Asks:   asks.sorted(limit),
+		Time:   now,
+	}, nil
 }
 
+// BestBidBestAsk лучшие цены, у пустой стороны стакана цена 0
 func (c *AzBitConnector) BestBidBestAsk(base, quote string) (bestBid, bestAsk float64, err error) {
 	res, err := c.Client.OrderBook(base, quote)
 	if err != nil {
 		return 0, 0, err
 	}
-	bestBid = 0
-	bestAsk = math.MaxFloat64
 	for _, level := range res {
 		if level.IsBid {
 			if level.Price > bestBid {
+				bestBid = level.Price
 			}
-			bestBid = level.Price
-		} else {
-			if level.Price < bestAsk {
-				bestAsk = level.Price
-			}
+		} else if bestAsk == 0 || level.Price < bestAsk {
+			bestAsk = level.Price
 		}
 	}
 	return
@@ -178,6 +225,63 @@ func (c *AzBitConnector) LastPrice(base, quote string) (lastPrice float64, err e
 	return deals[0].Price, nil
 }
 
+// Ticker берет статистику из /api/tickers, при ошибке считает ее по сделкам
+func (c *AzBitConnector) Ticker(base, quote string) (*Ticker, error) {
+	ticker, err := c.nativeTicker(base, quote)
+	if err == nil {
+		return ticker, nil
+	}
+	ticker, fallbackErr := TickerFromDealHistory(c, base, quote)
+	if fallbackErr != nil {
+		return nil, errors.Join(err, fallbackErr)
+	}
+	return ticker, nil
+}
+
+func (c *AzBitConnector) nativeTicker(base, quote string) (*Ticker, error) {
+	apiURL := c.ApiURL
+	if apiURL == "" {
+		apiURL = AzBitApiURL
+	}
+	resp, err := azBitHttpClient.Get(apiURL + "/api/tickers?currencyPairCode=" + url.QueryEscape(symbol(base, quote)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("azbit tickers: %s", resp.Status)
+	}
+	var tickers []azBitTicker
+	if err = json.NewDecoder(resp.Body).Decode(&tickers); err != nil {
+		return nil, fmt.Errorf("azbit tickers: %w", err)
+	}
+	for _, t := range tickers {
+		if t.CurrencyPairCode != symbol(base, quote) {
+			continue
+		}
+		if t.Price <= 0 {
+			return nil, fmt.Errorf("azbit ticker for %s has no price", symbol(base, quote))
+		}
+		ticker := &Ticker{
+			Symbol:        symbol(base, quote),
+			Last:          t.Price,
+			Bid:           t.BidPrice,
+			Ask:           t.AskPrice,
+			High:          t.High24h,
+			Low:           t.Low24h,
+			BaseVolume:    t.Volume24h,
+			QuoteVolume:   t.Volume24h * t.Price,
+			ChangePercent: t.PriceChange24h,
+			Time:          time.Now().UTC(),
+		}
+		if t.PriceChange24h > -100 {
+			ticker.Open = t.Price / (1 + t.PriceChange24h/100)
+		}
+		return ticker, nil
+	}
+	return nil, fmt.Errorf("azbit ticker for %s not found", symbol(base, quote))
+}
+
 func (c *AzBitConnector) CurrencyBalance(currency string) (available, freeze float64, err error) {
 	balances, err := c.Client.Balances()
 	if err != nil {
@@ -197,8 +301,8 @@ func (c *AzBitConnector) DealHistory(base, quote string, startTime, endTime int6
 	var limit = 100
 	req := azbitgosdk.DealsRequest{
 		CurrencyPairCode: symbol(base, quote),
-		SinceDate:        time.UnixMilli(startTime).Format("2006-01-02T15:04:05"),
-		EndDate:          time.UnixMilli(endTime).Format("2006-01-02T15:04:05"),
+		SinceDate:        time.UnixMilli(startTime).Format(azBitTimeLayout),
+		EndDate:          time.UnixMilli(endTime).Format(azBitTimeLayout),
 		PageSize:         limit,
 		PageNumber:       offset,
 	}
@@ -233,8 +337,14 @@ func (c *AzBitConnector) DealHistory(base, quote string, startTime, endTime int6
 }
 
 func DealToLevelAz(d azbitgosdk.Deal) (*Level, error) {
+	dealTime, err := time.Parse(azBitTimeLayout, d.DealDateUtc)
+	if err != nil {
+		return nil, err
+	}
 	level := &Level{
 		Price: d.Price,
+		ID:    d.Id,
+		Time:  dealTime,
 	}
 	if !d.IsBuy {
 		level.SellAmount = d.Volume
@@ -0,0 +1,32 @@
+package exchange_models
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAzBitConnector_Ticker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/tickers", r.URL.Path)
+		assert.Equal(t, "SDFA_USDT", r.URL.Query().Get("currencyPairCode"))
+		_, _ = w.Write([]byte(`[{"currencyPairCode":"SDFA_USDT","price":52,"priceChange24h":4,"volume24h":10,"bidPrice":51.9,"askPrice":52.1,"high24h":55,"low24h":49}]`))
+	}))
+	defer server.Close()
+
+	c, err := NewAzBitConnector("", "")
+	require.NoError(t, err)
+	c.ApiURL = server.URL
+	ticker, err := c.Ticker("SDFA", "USDT")
+	require.NoError(t, err)
+	assert.Equal(t, 52.0, ticker.Last)
+	assert.Equal(t, 51.9, ticker.Bid)
+	assert.Equal(t, 52.1, ticker.Ask)
+	assert.InDelta(t, 50.0, ticker.Open, 1e-9)
+	assert.Equal(t, 55.0, ticker.High)
+	assert.Equal(t, 10.0, ticker.BaseVolume)
+	assert.Equal(t, 4.0, ticker.ChangePercent)
+}
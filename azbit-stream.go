@@ -0,0 +1,187 @@
+package exchange_models
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const AzBitWsURL = "wss://data.azbit.com/ws"
+
+// AzBitStreamingConnector получает стакан и сделки через websocket AzBit,
+// наши ордера опрашиваются через REST
+type AzBitStreamingConnector struct {
+	*AzBitConnector
+	URL                string
+	OrdersPollInterval time.Duration
+
+	hub *streamHub
+	mu  sync.Mutex
+	ws  *wsConn
+}
+
+type azBitWsRequest struct {
+	Method           string `json:"method"`
+	Channel          string `json:"channel"`
+	CurrencyPairCode string `json:"currencyPairCode"`
+}
+
+type azBitWsMessage struct {
+	Channel          string          `json:"channel"`
+	CurrencyPairCode string          `json:"currencyPairCode"`
+	Sequence         int64           `json:"sequence"`
+	IsSnapshot       bool            `json:"isSnapshot"`
+	Data             json.RawMessage `json:"data"`
+}
+
+type azBitWsBook struct {
+	Bids []azBitWsLevel `json:"bids"`
+	Asks []azBitWsLevel `json:"asks"`
+}
+
+type azBitWsLevel struct {
+	Price  float64 `json:"price"`
+	Amount float64 `json:"amount"`
+}
+
+type azBitWsDeal struct {
+	Id          string  `json:"id"`
+	DealDateUtc string  `json:"dealDateUtc"`
+	Price       float64 `json:"price"`
+	Volume      float64 `json:"volume"`
+	IsBuy       bool    `json:"isBuy"`
+}
+
+func NewAzBitStreamingConnector(publicKey, secretKey string) (*AzBitStreamingConnector, error) {
+	connector, err := NewAzBitConnector(publicKey, secretKey)
+	if err != nil {
+		return nil, err
+	}
+	return &AzBitStreamingConnector{
+		AzBitConnector:     connector,
+		URL:                AzBitWsURL,
+		OrdersPollInterval: defaultOrdersPollInterval,
+		hub:                newStreamHub(),
+	}, nil
+}
+
+func (c *AzBitStreamingConnector) SubscribeOrderBook(base, quote string) (<-chan *BookUpdate, error) {
+	ch, err := c.hub.addBook(symbol(base, quote))
+	if err != nil {
+		return nil, err
+	}
+	return ch, c.send("orderbook", symbol(base, quote))
+}
+
+func (c *AzBitStreamingConnector) SubscribeTrades(base, quote string) (<-chan *Trade, error) {
+	ch, err := c.hub.addTrades(symbol(base, quote))
+	if err != nil {
+		return nil, err
+	}
+	return ch, c.send("deals", symbol(base, quote))
+}
+
+func (c *AzBitStreamingConnector) SubscribeOrders(base, quote string, basePrecision, pricePrecision int) (<-chan *NetOrder, error) {
+	return c.hub.pollOrders(c.AzBitConnector, base, quote, basePrecision, pricePrecision, c.OrdersPollInterval)
+}
+
+func (c *AzBitStreamingConnector) Errors() <-chan error {
+	return c.hub.errs
+}
+
+func (c *AzBitStreamingConnector) Close() error {
+	c.hub.close(func() {
+		c.mu.Lock()
+		ws := c.ws
+		c.mu.Unlock()
+		if ws != nil {
+			ws.close()
+		}
+	})
+	return nil
+}
+
+func (c *AzBitStreamingConnector) send(channel, pair string) error {
+	msg, err := json.Marshal(azBitWsRequest{
+		Method:           "subscribe",
+		Channel:          channel,
+		CurrencyPairCode: pair,
+	})
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if c.ws == nil {
+		c.ws = newWsConn(c.URL, c.handle, nil, c.hub.errs)
+	}
+	ws := c.ws
+	c.mu.Unlock()
+	return ws.subscribe(msg)
+}
+
+func (c *AzBitStreamingConnector) handle(raw []byte) {
+	var msg azBitWsMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		c.hub.reportError(err)
+		return
+	}
+	var err error
+	switch msg.Channel {
+	case "orderbook":
+		err = c.handleBook(&msg)
+	case "deals":
+		err = c.handleDeals(&msg)
+	}
+	if err != nil {
+		c.hub.reportError(err)
+	}
+}
+
+func (c *AzBitStreamingConnector) handleBook(msg *azBitWsMessage) error {
+	var book azBitWsBook
+	if err := json.Unmarshal(msg.Data, &book); err != nil {
+		return err
+	}
+	update := &BookUpdate{
+		Symbol:   msg.CurrencyPairCode,
+		Snapshot: msg.IsSnapshot,
+		Sequence: msg.Sequence,
+		Bids:     make([]*Level, 0, len(book.Bids)),
+		Asks:     make([]*Level, 0, len(book.Asks)),
+		Time:     time.Now().UTC(),
+	}
+	for _, l := range book.Bids {
+		update.Bids = append(update.Bids, &Level{Price: l.Price, BuyAmount: l.Amount})
+	}
+	for _, l := range book.Asks {
+		update.Asks = append(update.Asks, &Level{Price: l.Price, SellAmount: l.Amount})
+	}
+	c.hub.publishBook(update)
+	return nil
+}
+
+func (c *AzBitStreamingConnector) handleDeals(msg *azBitWsMessage) error {
+	var deals []azBitWsDeal
+	if err := json.Unmarshal(msg.Data, &deals); err != nil {
+		return err
+	}
+	for _, d := range deals {
+		side := Sell
+		if d.IsBuy {
+			side = Buy
+		}
+		dealTime, err := time.Parse(azBitTimeLayout, d.DealDateUtc)
+		if err != nil {
+			return err
+		}
+		c.hub.publishTrade(&Trade{
+			ID:     d.Id,
+			Symbol: msg.CurrencyPairCode,
+			Side:   side,
+			Price:  d.Price,
+			Amount: d.Volume,
+			Time:   dealTime,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package exchange_models
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// Strategy бот, которого можно вести по шагам поверх SimConnector: MarketMakerBot, GridBot.
+// У TradingBot нет шага, его логику можно обернуть в StrategyFunc
+type Strategy interface {
+	Step() error
+}
+
+// StrategyFunc функция шага как Strategy
+type StrategyFunc func() error
+
+func (f StrategyFunc) Step() error {
+	return f()
+}
+
+type BacktestConfig struct {
+	Start time.Time // по умолчанию время первого события в данных
+	End   time.Time // по умолчанию время последнего события
+	Step  time.Duration
+}
+
+type InventoryPoint struct {
+	Time   time.Time
+	Base   float64
+	Quote  float64
+	Price  float64 // цена оценки: последняя сделка или середина стакана
+	Equity float64 // стоимость портфеля в quote
+}
+
+type BacktestReport struct {
+	StartEquity  float64
+	EndEquity    float64
+	PnL          float64 // в quote
+	Fees         float64 // в quote
+	Inventory    []*InventoryPoint
+	Posted       int     // выставлено ордеров
+	PostedAmount float64 // выставлено base
+	FilledAmount float64 // исполнено base
+	FillRate     float64 // доля исполненного объема от выставленного
+	MaxDrawdown  float64 // наибольшее падение стоимости от максимума, доля
+	Turnover     float64 // объем исполнений в quote
+	Fills        []*SimFill
+}
+
+// Backtest ведет стратегию по записанным данным: на каждом шаге часы SimConnector
+// сдвигаются на Step, наши ордера исполняются, затем вызывается strategy.Step
+func Backtest(sim *SimConnector, strategy Strategy, config *BacktestConfig) (*BacktestReport, error) {
+	if config.Step <= 0 {
+		return nil, fmt.Errorf("backtest step must be positive, got %s", config.Step)
+	}
+	start, end := config.Start, config.End
+	if start.IsZero() {
+		start = sim.Now()
+	}
+	if end.IsZero() {
+		end = sim.End()
+	}
+	if end.Before(start) {
+		return nil, errors.New("backtest end is before start")
+	}
+	report := &BacktestReport{}
+	sim.Advance(start)
+	report.Inventory = append(report.Inventory, sim.inventory())
+	var stepErr error
+	for now := start; !now.After(end); now = now.Add(config.Step) {
+		sim.Advance(now)
+		if err := strategy.Step(); err != nil {
+			stepErr = fmt.Errorf("step at %s: %w", now, err)
+			break
+		}
+		report.Inventory = append(report.Inventory, sim.inventory())
+	}
+	sim.Advance(end)
+	report.Inventory = append(report.Inventory, sim.inventory())
+	report.fill(sim)
+	return report, stepErr
+}
+
+func (c *SimConnector) inventory() *InventoryPoint {
+	baseAvailable, baseFreeze := c.engine.currencyBalance(c.config.Base)
+	quoteAvailable, quoteFreeze := c.engine.currencyBalance(c.config.Quote)
+	price, _ := c.LastPrice(c.config.Base, c.config.Quote)
+	if price == 0 {
+		bid, ask, _ := c.BestBidBestAsk(c.config.Base, c.config.Quote)
+		price = (bid + ask) / 2
+	}
+	point := &InventoryPoint{
+		Time:  c.Now(),
+		Base:  baseAvailable + baseFreeze,
+		Quote: quoteAvailable + quoteFreeze,
+		Price: price,
+	}
+	point.Equity = point.Base*point.Price + point.Quote
+	return point
+}
+
+func (r *BacktestReport) fill(sim *SimConnector) {
+	first, last := r.Inventory[0], r.Inventory[len(r.Inventory)-1]
+	r.StartEquity, r.EndEquity = first.Equity, last.Equity
+	r.PnL = r.EndEquity - r.StartEquity
+	peak := math.Inf(-1)
+	for _, point := range r.Inventory {
+		peak = math.Max(peak, point.Equity)
+		if peak > 0 {
+			r.MaxDrawdown = math.Max(r.MaxDrawdown, (peak-point.Equity)/peak)
+		}
+	}
+	sim.engine.mu.Lock()
+	r.Posted, r.PostedAmount = sim.engine.posted, sim.engine.postedAmount
+	sim.engine.mu.Unlock()
+	r.Fills = sim.Fills()
+	for _, fill := range r.Fills {
+		r.FilledAmount += fill.Amount
+		r.Turnover += fill.Amount * fill.Price
+		r.Fees += fill.Fee
+	}
+	if r.PostedAmount > 0 {
+		r.FillRate = r.FilledAmount / r.PostedAmount
+	}
+}
+
+func (r *BacktestReport) Print() {
+	r.Fprint(os.Stdout)
+}
+
+func (r *BacktestReport) Fprint(w io.Writer) {
+	fmt.Fprintf(w, "equity: %f -> %f, pnl: %f, fees: %f\n", r.StartEquity, r.EndEquity, r.PnL, r.Fees)
+	fmt.Fprintf(w, "orders: %d, fill rate: %.2f%%, turnover: %f, max drawdown: %.2f%%\n",
+		r.Posted, r.FillRate*100, r.Turnover, r.MaxDrawdown*100)
+}
@@ -0,0 +1,188 @@
+package exchange_models
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testBook(at time.Time, bid, ask, amount float64) *OrderBookSnapshot {
+	return &OrderBookSnapshot{
+		Symbol: symbol("SDFA", "USDT"),
+		Bids:   []*BookLevel{{Side: Buy, Price: bid, Amount: amount}},
+		Asks:   []*BookLevel{{Side: Sell, Price: ask, Amount: amount}},
+		Time:   at,
+	}
+}
+
+func TestMatchingEngine(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sim, err := NewSimConnector(&SimConfig{
+		Base: "SDFA", Quote: "USDT", BasePrecision: 3, PricePrecision: 2,
+		Books: []*OrderBookSnapshot{testBook(start, 99, 101, 2)},
+		Deals: []*Level{
+			{Price: 99, SellAmount: 1.5, Time: start.Add(time.Second)},
+			{Price: 99, SellAmount: 1, Time: start.Add(2 * time.Second)},
+			{Price: 98, SellAmount: 3, Time: start.Add(3 * time.Second)},
+		},
+		FillModel: QueueFillModel{},
+		MakerFee:  0.001,
+		TakerFee:  0.002,
+		Balances:  map[string]float64{"USDT": 1000, "SDFA": 1},
+	})
+	assert.NoError(t, err)
+	sim.Advance(start)
+
+	// покупка встает за 2 SDFA в очереди на 99
+	id, err := sim.PostLimitOrder("SDFA", "USDT", Buy, 1, 99, 3, 2)
+	assert.NoError(t, err)
+	// под покупку замораживается и наибольшая комиссия
+	available, freeze, _ := sim.CurrencyBalance("USDT")
+	assert.InDelta(t, 1000-99*1.002, available, 1e-9)
+	assert.InDelta(t, 99*1.002, freeze, 1e-9)
+
+	sim.Advance(start.Add(time.Second))
+	assert.Empty(t, sim.Fills())
+	sim.Advance(start.Add(2 * time.Second))
+	fills := sim.Fills()
+	assert.Len(t, fills, 1)
+	assert.Equal(t, 0.5, fills[0].Amount)
+	orders, _ := sim.AllOpenOrders("SDFA", "USDT", 3, 2)
+	assert.Len(t, orders, 1)
+	assert.Equal(t, 0.5, orders[0].UnfilledAmount())
+
+	// сделка ниже нашей цены исполняет остаток
+	sim.Advance(start.Add(3 * time.Second))
+	orders, _ = sim.AllOpenOrders("SDFA", "USDT", 3, 2)
+	assert.Empty(t, orders)
+	base, _, _ := sim.CurrencyBalance("SDFA")
+	assert.Equal(t, 2.0, base)
+	available, freeze, _ = sim.CurrencyBalance("USDT")
+	assert.InDelta(t, 1000-99-99*0.001, available, 1e-9)
+	assert.InDelta(t, 0, freeze, 1e-9)
+	assert.Error(t, sim.CancelOrder(id, "SDFA", "USDT"))
+
+	// продажа через стакан исполняется сразу как taker по цене покупки
+	_, err = sim.PostLimitOrder("SDFA", "USDT", Sell, 1, 98, 3, 2)
+	assert.NoError(t, err)
+	fills = sim.Fills()
+	assert.True(t, fills[len(fills)-1].Taker)
+	assert.Equal(t, 99.0, fills[len(fills)-1].Price)
+
+	_, err = sim.PostLimitOrder("SDFA", "USDT", Sell, 5, 120, 3, 2)
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+}
+
+func TestBacktest_MarketMaker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var books []*OrderBookSnapshot
+	var deals []*Level
+	mids := []float64{100, 100.5, 101, 100.5, 100, 99.5, 99, 99.5, 100, 100.5}
+	for i, mid := range mids {
+		at := start.Add(time.Duration(i) * time.Minute)
+		books = append(books, testBook(at, mid-0.5, mid+0.5, 10))
+		deals = append(deals,
+			&Level{Price: mid - 0.6, SellAmount: 2, Time: at.Add(20 * time.Second)},
+			&Level{Price: mid + 0.6, BuyAmount: 2, Time: at.Add(40 * time.Second)},
+		)
+	}
+	sim, err := NewSimConnector(&SimConfig{
+		Base: "SDFA", Quote: "USDT", BasePrecision: 3, PricePrecision: 2,
+		Books: books, Deals: deals,
+		MakerFee: 0.001,
+		Balances: map[string]float64{"USDT": 1000, "SDFA": 10},
+	})
+	assert.NoError(t, err)
+	bot, err := NewMarketMakerBot(sim, &MarketMakerConfig{
+		Symbol:          SymbolInfo{Base: "SDFA", Quote: "USDT", BasePrecision: 3, PricePrecision: 2},
+		SpreadBps:       30,
+		LevelStepBps:    10,
+		LevelsPerSide:   2,
+		OrderBaseAmount: 1,
+	})
+	assert.NoError(t, err)
+
+	report, err := Backtest(sim, bot, &BacktestConfig{Step: 10 * time.Second})
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	report.Fprint(&buf)
+	t.Log(buf.String())
+	assert.NotEmpty(t, report.Fills)
+	assert.Greater(t, report.Posted, 0)
+	assert.Greater(t, report.FillRate, 0.0)
+	assert.LessOrEqual(t, report.FillRate, 1.0)
+	assert.Greater(t, report.Turnover, 0.0)
+	assert.Greater(t, report.Fees, 0.0)
+	assert.GreaterOrEqual(t, report.MaxDrawdown, 0.0)
+	assert.Equal(t, 2000.0, report.StartEquity)
+	assert.InDelta(t, report.EndEquity-report.StartEquity, report.PnL, 1e-9)
+	assert.Equal(t, sim.End(), report.Inventory[len(report.Inventory)-1].Time)
+}
+
+func TestBacktest_StrategyFunc(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sim, err := NewSimConnector(&SimConfig{
+		Base: "SDFA", Quote: "USDT", BasePrecision: 3, PricePrecision: 2,
+		Books:    []*OrderBookSnapshot{testBook(start, 99, 101, 2), testBook(start.Add(time.Minute), 99, 101, 2)},
+		Balances: map[string]float64{"USDT": 1000},
+	})
+	assert.NoError(t, err)
+	steps := 0
+	report, err := Backtest(sim, StrategyFunc(func() error {
+		steps++
+		return nil
+	}), &BacktestConfig{Step: 10 * time.Second})
+	assert.NoError(t, err)
+	assert.Equal(t, 7, steps)
+	assert.Equal(t, 0.0, report.PnL)
+}
+
+func TestMatchingEngine_BookLiquidity(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sim, err := NewSimConnector(&SimConfig{
+		Base: "SDFA", Quote: "USDT", BasePrecision: 3, PricePrecision: 2,
+		Books:    []*OrderBookSnapshot{testBook(start, 99, 101, 1), testBook(start.Add(time.Minute), 99, 101, 1)},
+		Balances: map[string]float64{"SDFA": 5},
+	})
+	assert.NoError(t, err)
+	sim.Advance(start)
+
+	// бид 99 отдает только 1 SDFA на два ордера и не восстанавливается в том же стакане
+	_, err = sim.PostLimitOrder("SDFA", "USDT", Sell, 0.6, 99, 3, 2)
+	assert.NoError(t, err)
+	_, err = sim.PostLimitOrder("SDFA", "USDT", Sell, 0.6, 99, 3, 2)
+	assert.NoError(t, err)
+	sim.Advance(start.Add(time.Minute))
+	orders, _ := sim.AllOpenOrders("SDFA", "USDT", 3, 2)
+	assert.Len(t, orders, 1)
+	assert.Equal(t, 0.4, orders[0].FilledAmount())
+	var filled float64
+	for _, fill := range sim.Fills() {
+		filled += fill.Amount
+	}
+	assert.Equal(t, 1.0, filled)
+}
+
+func TestMatchingEngine_BuyFeeReserve(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sim, err := NewSimConnector(&SimConfig{
+		Base: "SDFA", Quote: "USDT", BasePrecision: 3, PricePrecision: 2,
+		Books:    []*OrderBookSnapshot{testBook(start, 99, 100, 5)},
+		TakerFee: 0.002,
+		Balances: map[string]float64{"USDT": 100},
+	})
+	assert.NoError(t, err)
+	sim.Advance(start)
+
+	// весь баланс не покрывает комиссию
+	_, err = sim.PostLimitOrder("SDFA", "USDT", Buy, 1, 100, 3, 2)
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+	_, err = sim.PostLimitOrder("SDFA", "USDT", Buy, 0.998, 100, 3, 2)
+	assert.NoError(t, err)
+	available, freeze, _ := sim.CurrencyBalance("USDT")
+	assert.GreaterOrEqual(t, available, 0.0)
+	assert.InDelta(t, 100-99.8*1.002, available, 1e-9)
+	assert.InDelta(t, 0, freeze, 1e-9)
+}
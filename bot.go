@@ -18,3 +18,10 @@ type SymbolInfo struct {
 	PricePrecision int
 	BasePrecision  int
 }
+
+// configCopy поверхностная копия конфига. Конструкторы пишут значения по умолчанию
+// в копию, чтобы не менять конфиг вызывающего
+func configCopy[T any](config *T) *T {
+	c := *config
+	return &c
+}
@@ -0,0 +1,56 @@
+package exchange_models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// конструкторы пишут значения по умолчанию в копию конфига
+func TestConstructors_KeepConfig(t *testing.T) {
+	symbol := SymbolInfo{Base: "SDFA", Quote: "USDT", BasePrecision: 3, PricePrecision: 2}
+	tests := []struct {
+		name string
+		new  func() (before, after any, err error)
+	}{
+		{"market maker", func() (any, any, error) {
+			config := &MarketMakerConfig{SpreadBps: 50, LevelsPerSide: 1, OrderBaseAmount: 1}
+			before := *config
+			_, err := NewMarketMakerBot(newMockConnector(), config)
+			return before, *config, err
+		}},
+		{"grid", func() (any, any, error) {
+			config := &GridConfig{Symbol: symbol, Range: &Spread{TopPrice: 105, BottomPrice: 95}, Levels: 11, OrderBaseAmount: 0.5}
+			before := *config
+			_, err := NewGridBot(newMockConnector(), config)
+			return before, *config, err
+		}},
+		{"execution", func() (any, any, error) {
+			config := &ExecutionConfig{Symbol: symbol, Algo: TWAP, Side: Buy, BaseAmount: 2, Duration: time.Second, Slices: 4}
+			before := *config
+			_, err := NewExecution(newMockConnector(), config)
+			return before, *config, err
+		}},
+		{"arbitrage", func() (any, any, error) {
+			_, _, venues := newTestVenues()
+			config := &ArbitrageConfig{Venues: venues}
+			before := *config
+			_, err := NewArbitrageMonitor(config)
+			return before, *config, err
+		}},
+		{"paper", func() (any, any, error) {
+			config := &PaperConfig{Balances: map[string]float64{"usdt": 100}}
+			before := *config
+			_, err := NewPaperConnector(newMockConnector(), config)
+			return before, *config, err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after, err := tt.new()
+			assert.NoError(t, err)
+			assert.Equal(t, before, after)
+		})
+	}
+}
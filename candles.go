@@ -0,0 +1,207 @@
+package exchange_models
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Candle struct {
+	Time        time.Time // начало интервала
+	Open        float64
+	High        float64
+	Low         float64
+	Close       float64
+	Volume      float64 // объем в base
+	QuoteVolume float64
+	BuyVolume   float64
+	SellVolume  float64
+	Trades      int
+
+	openTime  time.Time
+	closeTime time.Time
+}
+
+// CandleBuilder собирает OHLCV свечи из сделок DealHistory и потока сделок.
+// Сделки могут приходить в любом порядке, повторы с тем же ID пропускаются
+type CandleBuilder struct {
+	interval time.Duration
+	fillGaps bool
+
+	mu      sync.Mutex
+	candles map[int64]*Candle
+	seen    map[string]bool
+}
+
+func NewCandleBuilder(interval time.Duration, fillGaps bool) (*CandleBuilder, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("candle interval must be positive, got %s", interval)
+	}
+	return &CandleBuilder{
+		interval: interval,
+		fillGaps: fillGaps,
+		candles:  make(map[int64]*Candle),
+		seen:     make(map[string]bool),
+	}, nil
+}
+
+// BuildCandles свечи по сделкам из DealHistory
+func BuildCandles(deals []*Level, interval time.Duration, fillGaps bool) ([]*Candle, error) {
+	builder, err := NewCandleBuilder(interval, fillGaps)
+	if err != nil {
+		return nil, err
+	}
+	if err = builder.AddDeals(deals); err != nil {
+		return nil, err
+	}
+	return builder.Candles(), nil
+}
+
+func (b *CandleBuilder) AddDeals(deals []*Level) error {
+	for _, deal := range deals {
+		if err := b.AddDeal(deal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *CandleBuilder) AddDeal(deal *Level) error {
+	if deal.Time.IsZero() {
+		return errors.New("deal has no time")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if deal.ID != "" {
+		if b.seen[deal.ID] {
+			return nil
+		}
+		b.seen[deal.ID] = true
+	}
+	start := deal.Time.Truncate(b.interval)
+	amount := deal.BuyAmount + deal.SellAmount
+	candle, ok := b.candles[start.UnixNano()]
+	if !ok {
+		candle = &Candle{
+			Time:      start,
+			Open:      deal.Price,
+			High:      deal.Price,
+			Low:       deal.Price,
+			Close:     deal.Price,
+			openTime:  deal.Time,
+			closeTime: deal.Time,
+		}
+		b.candles[start.UnixNano()] = candle
+	}
+	if deal.Price > candle.High {
+		candle.High = deal.Price
+	}
+	if deal.Price < candle.Low {
+		candle.Low = deal.Price
+	}
+	if deal.Time.Before(candle.openTime) {
+		candle.Open = deal.Price
+		candle.openTime = deal.Time
+	}
+	if !deal.Time.Before(candle.closeTime) {
+		candle.Close = deal.Price
+		candle.closeTime = deal.Time
+	}
+	candle.Volume += amount
+	candle.QuoteVolume += amount * deal.Price
+	candle.BuyVolume += deal.BuyAmount
+	candle.SellVolume += deal.SellAmount
+	candle.Trades++
+	return nil
+}
+
+func (b *CandleBuilder) AddTrade(trade *Trade) error {
+	return b.AddDeal(trade.Level())
+}
+
+// Run добавляет сделки из потока, пока канал не закроется
+func (b *CandleBuilder) Run(trades <-chan *Trade) error {
+	for trade := range trades {
+		if err := b.AddTrade(trade); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Candles свечи по возрастанию времени. При fillGaps интервалы без сделок
+// заполняются пустыми свечами по цене закрытия предыдущей
+func (b *CandleBuilder) Candles() []*Candle {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	candles := make([]*Candle, 0, len(b.candles))
+	for _, candle := range b.candles {
+		c := *candle
+		candles = append(candles, &c)
+	}
+	sort.Slice(candles, func(i, j int) bool {
+		return candles[i].Time.Before(candles[j].Time)
+	})
+	if !b.fillGaps || len(candles) < 2 {
+		return candles
+	}
+	filled := make([]*Candle, 0, len(candles))
+	for idx, candle := range candles {
+		if idx > 0 {
+			prev := filled[len(filled)-1]
+			for next := prev.Time.Add(b.interval); next.Before(candle.Time); next = next.Add(b.interval) {
+				filled = append(filled, &Candle{
+					Time:  next,
+					Open:  prev.Close,
+					High:  prev.Close,
+					Low:   prev.Close,
+					Close: prev.Close,
+				})
+			}
+		}
+		filled = append(filled, candle)
+	}
+	return filled
+}
+
+var candleCSVHeader = []string{"time", "open", "high", "low", "close", "volume", "quote_volume", "buy_volume", "sell_volume", "trades"}
+
+func WriteCandlesCSV(w io.Writer, candles []*Candle) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(candleCSVHeader); err != nil {
+		return err
+	}
+	for _, c := range candles {
+		record := []string{
+			c.Time.UTC().Format(time.RFC3339),
+			formatFloat(c.Open),
+			formatFloat(c.High),
+			formatFloat(c.Low),
+			formatFloat(c.Close),
+			formatFloat(c.Volume),
+			formatFloat(c.QuoteVolume),
+			formatFloat(c.BuyVolume),
+			formatFloat(c.SellVolume),
+			strconv.Itoa(c.Trades),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func WriteCandlesJSON(w io.Writer, candles []*Candle) error {
+	return json.NewEncoder(w).Encode(candles)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,93 @@
+package exchange_models
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildCandles(t *testing.T) {
+	start := time.Date(2024, 6, 20, 13, 0, 0, 0, time.UTC)
+	deals := []*Level{
+		{ID: "4", Price: 56, SellAmount: 1, Time: start.Add(3*time.Minute + 10*time.Second)},
+		{ID: "2", Price: 57, BuyAmount: 2, Time: start.Add(30 * time.Second)},
+		{ID: "1", Price: 55, BuyAmount: 1, Time: start.Add(10 * time.Second)},
+		{ID: "3", Price: 54, SellAmount: 0.5, Time: start.Add(50 * time.Second)},
+		{ID: "3", Price: 54, SellAmount: 0.5, Time: start.Add(50 * time.Second)},
+	}
+
+	candles, err := BuildCandles(deals, time.Minute, false)
+	assert.NoError(t, err)
+	assert.Len(t, candles, 2)
+	first := candles[0]
+	assert.Equal(t, start, first.Time)
+	assert.Equal(t, 55.0, first.Open)
+	assert.Equal(t, 57.0, first.High)
+	assert.Equal(t, 54.0, first.Low)
+	assert.Equal(t, 54.0, first.Close)
+	assert.Equal(t, 3.5, first.Volume)
+	assert.Equal(t, 3.0, first.BuyVolume)
+	assert.Equal(t, 0.5, first.SellVolume)
+	assert.Equal(t, 55+57*2+54*0.5, first.QuoteVolume)
+	assert.Equal(t, 3, first.Trades)
+
+	candles, err = BuildCandles(deals, time.Minute, true)
+	assert.NoError(t, err)
+	assert.Len(t, candles, 4)
+	assert.Equal(t, start.Add(2*time.Minute), candles[2].Time)
+	assert.Equal(t, 54.0, candles[2].Open)
+	assert.Equal(t, 0.0, candles[2].Volume)
+	assert.Equal(t, 56.0, candles[3].Close)
+
+	_, err = BuildCandles([]*Level{{Price: 1, BuyAmount: 1}}, time.Minute, false)
+	assert.Error(t, err)
+	_, err = NewCandleBuilder(0, false)
+	assert.Error(t, err)
+}
+
+func TestCandleBuilder_Run(t *testing.T) {
+	builder, err := NewCandleBuilder(time.Hour, false)
+	assert.NoError(t, err)
+	start := time.Date(2024, 6, 20, 13, 0, 0, 0, time.UTC)
+	assert.NoError(t, builder.AddDeal(&Level{ID: "1", Price: 10, BuyAmount: 1, Time: start}))
+
+	trades := make(chan *Trade, 2)
+	trades <- &Trade{ID: "1", Side: Buy, Price: 10, Amount: 1, Time: start}
+	trades <- &Trade{ID: "2", Side: Sell, Price: 11, Amount: 2, Time: start.Add(time.Minute)}
+	close(trades)
+	assert.NoError(t, builder.Run(trades))
+
+	candles := builder.Candles()
+	assert.Len(t, candles, 1)
+	assert.Equal(t, 2, candles[0].Trades)
+	assert.Equal(t, 11.0, candles[0].Close)
+	assert.Equal(t, 2.0, candles[0].SellVolume)
+}
+
+func TestWriteCandles(t *testing.T) {
+	candles := []*Candle{{
+		Time:   time.Date(2024, 6, 20, 13, 0, 0, 0, time.UTC),
+		Open:   55.1,
+		High:   56,
+		Low:    55,
+		Close:  55.5,
+		Volume: 1.25,
+		Trades: 2,
+	}}
+	var buf bytes.Buffer
+	assert.NoError(t, WriteCandlesCSV(&buf, candles))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "2024-06-20T13:00:00Z,55.1,56,55,55.5,1.25,0,0,0,2", lines[1])
+
+	buf.Reset()
+	assert.NoError(t, WriteCandlesJSON(&buf, candles))
+	var decoded []*Candle
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, candles[0].Close, decoded[0].Close)
+	assert.True(t, candles[0].Time.Equal(decoded[0].Time))
+}
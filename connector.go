@@ -7,8 +7,16 @@ type Connector interface {
 	OpenOrders(base, quote string, basePrecision, pricePrecision int, offset, limit int64) ([]*NetOrder, error)
 	OrderBook(base, quote string, side Side, basePrecision, pricePrecision int, offset, limit int64) ([]*NetOrder, error)
 	FullOrderBook(base, quote string, side Side, basePrecision, pricePrecision int) ([]*NetOrder, error)
+	Depth(base, quote string, basePrecision, pricePrecision int, limit int64) (*OrderBookSnapshot, error)
 	BestBidBestAsk(base, quote string) (bestBid, bestAsk float64, err error)
 	LastPrice(base, quote string) (lastPrice float64, err error)
+	Ticker(base, quote string) (*Ticker, error)
 	DealHistory(base, quote string, startTime, endTime int64) ([]*Level, error)
 	CurrencyBalance(currency string) (available, freeze float64, err error)
 }
+
+// OrderLookup коннектор, который отдает ордер по id и после его закрытия,
+// с итоговым исполненным объемом
+type OrderLookup interface {
+	OrderByID(orderId, base, quote string, basePrecision, pricePrecision int) (*NetOrder, error)
+}
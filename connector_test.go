@@ -0,0 +1,148 @@
+package exchange_models
+
+import (
+	"fmt"
+	"sync"
+)
+
+// mockConnector хранит стакан, ордера и балансы в памяти
+type mockConnector struct {
+	Connector
+	mu         sync.Mutex
+	buyBook    []*NetOrder
+	sellBook   []*NetOrder
+	openOrders []*NetOrder
+	closed     map[string]*NetOrder // снятые и исполненные ордера для lookupConnector
+	bestBid    float64
+	bestAsk    float64
+	lastPrice  float64
+	deals      []*Level
+	balances   map[string]float64
+	freezes    map[string]float64
+	cancelled  []string
+	bookCalls  int
+	nextId     int
+}
+
+func newMockConnector() *mockConnector {
+	return &mockConnector{
+		balances: make(map[string]float64),
+		freezes:  make(map[string]float64),
+		closed:   make(map[string]*NetOrder),
+	}
+}
+
+// lookupConnector mockConnector, который находит по id и закрытые ордера
+type lookupConnector struct {
+	*mockConnector
+}
+
+var _ OrderLookup = (*lookupConnector)(nil)
+
+func (c *lookupConnector) OrderByID(orderId, base, quote string, basePrecision, pricePrecision int) (*NetOrder, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, order := range c.openOrders {
+		if order.ID() == orderId {
+			return order.clone(), nil
+		}
+	}
+	if order, ok := c.closed[orderId]; ok {
+		return order.clone(), nil
+	}
+	return nil, fmt.Errorf("order %s not found", orderId)
+}
+
+func (c *mockConnector) PostLimitOrder(base, quote string, side Side, baseAmount, price float64, basePrecision, pricePrecision int) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextId++
+	id := fmt.Sprintf("%d", c.nextId)
+	order, err := NewNetOrder(&NetOrderConfig{
+		Id:         id,
+		Symbol:     symbol(base, quote),
+		Side:       side,
+		OrderType:  Limit,
+		Status:     New,
+		Price:      Round(price, pricePrecision),
+		BaseAmount: Round(baseAmount, basePrecision),
+		BasePrec:   basePrecision,
+		PricePrec:  pricePrecision,
+	})
+	if err != nil {
+		return "", err
+	}
+	c.openOrders = append(c.openOrders, order)
+	return id, nil
+}
+
+func (c *mockConnector) CancelOrder(orderId, base, quote string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for idx, order := range c.openOrders {
+		if order.ID() == orderId {
+			c.openOrders = append(c.openOrders[:idx], c.openOrders[idx+1:]...)
+			c.cancelled = append(c.cancelled, orderId)
+			c.closed[orderId] = order.clone().SetStatus(Cancelled)
+			return nil
+		}
+	}
+	return fmt.Errorf("order %s not found", orderId)
+}
+
+func (c *mockConnector) AllOpenOrders(base, quote string, basePrecision, pricePrecision int) ([]*NetOrder, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := make([]*NetOrder, len(c.openOrders))
+	copy(res, c.openOrders)
+	return res, nil
+}
+
+func (c *mockConnector) FullOrderBook(base, quote string, side Side, basePrecision, pricePrecision int) ([]*NetOrder, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bookCalls++
+	if side == Buy {
+		return c.buyBook, nil
+	}
+	return c.sellBook, nil
+}
+
+func (c *mockConnector) Depth(base, quote string, basePrecision, pricePrecision int, limit int64) (*OrderBookSnapshot, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &OrderBookSnapshot{
+		Symbol: symbol(base, quote),
+		Bids:   AggregateOrders(c.buyBook, Buy, basePrecision, pricePrecision, limit),
+		Asks:   AggregateOrders(c.sellBook, Sell, basePrecision, pricePrecision, limit),
+	}, nil
+}
+
+func (c *mockConnector) BestBidBestAsk(base, quote string) (bestBid, bestAsk float64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bestBid, c.bestAsk, nil
+}
+
+func (c *mockConnector) LastPrice(base, quote string) (float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastPrice, nil
+}
+
+func (c *mockConnector) DealHistory(base, quote string, startTime, endTime int64) ([]*Level, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deals, nil
+}
+
+func (c *mockConnector) CurrencyBalance(currency string) (available, freeze float64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.balances[currency], c.freezes[currency], nil
+}
+
+func mockBookOrder(side Side, price, amount float64) *NetOrder {
+	order, _ := NewNetOrder(&NetOrderConfig{Side: side, Price: price, BaseAmount: amount, BasePrec: 3, PricePrec: 2})
+	return order
+}
@@ -25,4 +25,5 @@ var (
 	New               OrderStatus = "New"
 	Cancelled         OrderStatus = "Cancelled"
 	CancelledNotFully OrderStatus = "CancelledNotFully"
+	Closed            OrderStatus = "Closed" // ордер пропал из открытых, причина неизвестна
 )
@@ -0,0 +1,116 @@
+package exchange_models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// BookLevel агрегированный уровень стакана: все чужие и наши заявки по одной цене
+type BookLevel struct {
+	Side       Side
+	Price      float64
+	Amount     float64
+	OrderCount int // 0 - неизвестно, биржа отдала уже агрегированный уровень
+	Time       time.Time
+}
+
+type OrderBookSnapshot struct {
+	Symbol string
+	Bids   []*BookLevel // по убыванию цены
+	Asks   []*BookLevel // по возрастанию цены
+	Time   time.Time
+}
+
+func (s *OrderBookSnapshot) Levels(side Side) []*BookLevel {
+	if side == Buy {
+		return s.Bids
+	}
+	return s.Asks
+}
+
+func (s *OrderBookSnapshot) Best(side Side) (*BookLevel, bool) {
+	levels := s.Levels(side)
+	if len(levels) == 0 {
+		return nil, false
+	}
+	return levels[0], true
+}
+
+func (s *OrderBookSnapshot) Print() {
+	fmt.Printf("%s %s\n", s.Symbol, s.Time)
+	for i := len(s.Asks) - 1; i >= 0; i-- {
+		s.Asks[i].Print()
+	}
+	fmt.Println("----------")
+	for _, l := range s.Bids {
+		l.Print()
+	}
+}
+
+func (l *BookLevel) Print() {
+	fmt.Printf("%s, price: %f, amount: %f, orders: %d\n", l.Side, l.Price, l.Amount, l.OrderCount)
+}
+
+// bookLevels собирает заявки одной стороны в уровни по цене
+type bookLevels struct {
+	side           Side
+	basePrecision  int
+	pricePrecision int
+	time           time.Time
+	byPrice        map[float64]*BookLevel
+}
+
+func newBookLevels(side Side, basePrecision, pricePrecision int, at time.Time) *bookLevels {
+	return &bookLevels{
+		side:           side,
+		basePrecision:  basePrecision,
+		pricePrecision: pricePrecision,
+		time:           at,
+		byPrice:        make(map[float64]*BookLevel),
+	}
+}
+
+// add добавляет одну заявку
+func (b *bookLevels) add(price, amount float64) {
+	b.addLevel(price, amount).OrderCount++
+}
+
+// addLevel добавляет уже агрегированный биржей уровень, количество заявок в нем неизвестно
+func (b *bookLevels) addLevel(price, amount float64) *BookLevel {
+	price = Round(price, b.pricePrecision)
+	level, ok := b.byPrice[price]
+	if !ok {
+		level = &BookLevel{Side: b.side, Price: price, Time: b.time}
+		b.byPrice[price] = level
+	}
+	level.Amount = Round(level.Amount+amount, b.basePrecision)
+	return level
+}
+
+// sorted возвращает уровни от лучшей цены, не больше limit (0 - все)
+func (b *bookLevels) sorted(limit int64) []*BookLevel {
+	levels := make([]*BookLevel, 0, len(b.byPrice))
+	for _, level := range b.byPrice {
+		levels = append(levels, level)
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if b.side == Buy {
+			return levels[i].Price > levels[j].Price
+		}
+		return levels[i].Price < levels[j].Price
+	})
+	if limit > 0 && int64(len(levels)) > limit {
+		levels = levels[:limit]
+	}
+	return levels
+}
+
+// AggregateOrders сворачивает заявки из OrderBook в уровни стакана
+func AggregateOrders(orders []*NetOrder, side Side, basePrecision, pricePrecision int, limit int64) []*BookLevel {
+	levels := newBookLevels(side, basePrecision, pricePrecision, time.Now().UTC())
+	for _, order := range orders {
+		levels.add(order.Price(), order.UnfilledAmount())
+	}
+	return levels.sorted(limit)
+}
@@ -0,0 +1,55 @@
+package exchange_models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateOrders(t *testing.T) {
+	partial := mockBookOrder(Sell, 55.1, 2)
+	assert.NoError(t, partial.AddFilledAmount(0.5))
+	orders := []*NetOrder{
+		mockBookOrder(Sell, 55.3, 1),
+		partial,
+		mockBookOrder(Sell, 55.1, 0.25),
+		mockBookOrder(Sell, 55.2, 3),
+	}
+
+	levels := AggregateOrders(orders, Sell, 3, 2, 0)
+	assert.Len(t, levels, 3)
+	assert.Equal(t, 55.1, levels[0].Price)
+	assert.Equal(t, 1.75, levels[0].Amount)
+	assert.Equal(t, 2, levels[0].OrderCount)
+	assert.Equal(t, Sell, levels[0].Side)
+	assert.Equal(t, 55.3, levels[2].Price)
+
+	levels = AggregateOrders(orders, Sell, 3, 2, 2)
+	assert.Len(t, levels, 2)
+	assert.Equal(t, 55.2, levels[1].Price)
+
+	// уровни, агрегированные биржей, не знают количества заявок
+	levels = p2bDepthLevels(Buy, [][]float64{{54.9, 1}, {55, 2}, {54.9, 0.5}}, 3, 2, time.Now(), 0)
+	assert.Len(t, levels, 2)
+	assert.Equal(t, 55.0, levels[0].Price)
+	assert.Equal(t, 1.5, levels[1].Amount)
+	assert.Zero(t, levels[1].OrderCount)
+}
+
+func TestLocalOrderBook_Snapshot(t *testing.T) {
+	book, c := newTestLocalOrderBook(t)
+	snapshot := book.Snapshot()
+	depth, err := c.Depth("SDFA", "USDT", 3, 2, 0)
+	assert.NoError(t, err)
+
+	assert.Equal(t, len(depth.Bids), len(snapshot.Bids))
+	for idx, level := range depth.Bids {
+		assert.Equal(t, level.Price, snapshot.Bids[idx].Price)
+		assert.Equal(t, level.Amount, snapshot.Bids[idx].Amount)
+	}
+	best, ok := snapshot.Best(Sell)
+	assert.True(t, ok)
+	assert.Equal(t, 55.1, best.Price)
+	assert.Equal(t, 2, depth.Bids[0].OrderCount)
+}
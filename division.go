@@ -0,0 +1,151 @@
+package exchange_models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// DivisionStrategy делит спред на parts непересекающихся непустых частей от верхней цены к нижней.
+// Соседние части делят границу, вместе части покрывают весь спред
+type DivisionStrategy interface {
+	Divide(baseSpread *Spread, parts int, pricePrecision int) ([]*Spread, error)
+}
+
+// EqualDivision части одинаковой длины
+type EqualDivision struct{}
+
+// GeometricDivision каждая следующая часть в Ratio раз длиннее предыдущей
+type GeometricDivision struct {
+	Ratio float64
+}
+
+// FibonacciDivision длины частей относятся как числа Фибоначчи: 1, 1, 2, 3, 5...
+type FibonacciDivision struct{}
+
+// LogPriceDivision части одинаковой длины в логарифме цены, то есть с одинаковым отношением цен
+type LogPriceDivision struct{}
+
+// VolatilityDivision границы частей стоят на ожидаемом движении цены Volatility*TopPrice*sqrt(t)
+// за t шагов. Верхняя часть равна движению за один шаг, нижняя граница - движению за n шагов,
+// которое проходит весь спред, промежуточные t идут геометрической прогрессией от 1 до n.
+// Чем выше Volatility, тем шире верхняя часть и равномернее остальные.
+// Volatility - относительное движение цены за один шаг
+type VolatilityDivision struct {
+	Volatility float64
+}
+
+func (EqualDivision) Divide(baseSpread *Spread, parts int, pricePrecision int) ([]*Spread, error) {
+	return divideByWeights(baseSpread, parts, pricePrecision, func(int) float64 { return 1 })
+}
+
+func (d GeometricDivision) Divide(baseSpread *Spread, parts int, pricePrecision int) ([]*Spread, error) {
+	if d.Ratio <= 0 {
+		return nil, fmt.Errorf("geometric ratio must be positive, got %f", d.Ratio)
+	}
+	return divideByWeights(baseSpread, parts, pricePrecision, func(k int) float64 {
+		return math.Pow(d.Ratio, float64(k))
+	})
+}
+
+func (FibonacciDivision) Divide(baseSpread *Spread, parts int, pricePrecision int) ([]*Spread, error) {
+	fib := make([]float64, 0, parts)
+	for k := 0; k < parts; k++ {
+		if k < 2 {
+			fib = append(fib, 1)
+		} else {
+			fib = append(fib, fib[k-1]+fib[k-2])
+		}
+	}
+	return divideByWeights(baseSpread, parts, pricePrecision, func(k int) float64 { return fib[k] })
+}
+
+func (LogPriceDivision) Divide(baseSpread *Spread, parts int, pricePrecision int) ([]*Spread, error) {
+	if baseSpread.BottomPrice <= 0 {
+		return nil, fmt.Errorf("log price division needs positive prices, got bottom price %f", baseSpread.BottomPrice)
+	}
+	if err := checkDivision(baseSpread, parts); err != nil {
+		return nil, err
+	}
+	ratio := baseSpread.BottomPrice / baseSpread.TopPrice
+	bounds := make([]float64, parts+1)
+	for k := range bounds {
+		bounds[k] = baseSpread.TopPrice * math.Pow(ratio, float64(k)/float64(parts))
+	}
+	return spreadsFromBounds(baseSpread, bounds, pricePrecision)
+}
+
+func (d VolatilityDivision) Divide(baseSpread *Spread, parts int, pricePrecision int) ([]*Spread, error) {
+	if d.Volatility <= 0 {
+		return nil, fmt.Errorf("volatility must be positive, got %f", d.Volatility)
+	}
+	if err := checkDivision(baseSpread, parts); err != nil {
+		return nil, err
+	}
+	step := d.Volatility * baseSpread.TopPrice
+	if step > baseSpread.Length()+precisionEps(pricePrecision) {
+		return nil, fmt.Errorf("spread %f - %f is narrower than one step move with volatility %f",
+			baseSpread.BottomPrice, baseSpread.TopPrice, d.Volatility)
+	}
+	// расстояние от верха до k-й границы step*sqrt(t_k), t_k = n^((k-1)/(parts-1)), step*sqrt(n) = длина спреда
+	ratio := baseSpread.Length() / step
+	bounds := make([]float64, parts+1)
+	for k := 1; k <= parts; k++ {
+		distance := baseSpread.Length()
+		if parts > 1 {
+			distance = step * math.Pow(ratio, float64(k-1)/float64(parts-1))
+		}
+		bounds[k] = baseSpread.TopPrice - distance
+	}
+	return spreadsFromBounds(baseSpread, bounds, pricePrecision)
+}
+
+func checkDivision(baseSpread *Spread, parts int) error {
+	if parts <= 0 {
+		return fmt.Errorf("parts amount must be positive, got %d", parts)
+	}
+	if baseSpread.Length() <= 0 {
+		return errors.New("spread top price must be above bottom price")
+	}
+	return nil
+}
+
+// divideByWeights делит спред на части с длинами, пропорциональными weight(k), k считается от верха
+func divideByWeights(baseSpread *Spread, parts int, pricePrecision int, weight func(k int) float64) ([]*Spread, error) {
+	if err := checkDivision(baseSpread, parts); err != nil {
+		return nil, err
+	}
+	var weightsSum float64
+	weights := make([]float64, parts)
+	for k := range weights {
+		weights[k] = weight(k)
+		weightsSum += weights[k]
+	}
+	bounds := make([]float64, parts+1)
+	bounds[0] = baseSpread.TopPrice
+	var passed float64
+	for k, w := range weights {
+		passed += w
+		bounds[k+1] = baseSpread.TopPrice - passed/weightsSum*baseSpread.Length()
+	}
+	return spreadsFromBounds(baseSpread, bounds, pricePrecision)
+}
+
+// spreadsFromBounds части между соседними границами. Границы округляются до точности цены,
+// крайние совпадают с краями спреда
+func spreadsFromBounds(baseSpread *Spread, bounds []float64, pricePrecision int) ([]*Spread, error) {
+	bounds[0] = baseSpread.TopPrice
+	bounds[len(bounds)-1] = baseSpread.BottomPrice
+	res := make([]*Spread, 0, len(bounds)-1)
+	top := Round(bounds[0], pricePrecision)
+	for k := 1; k < len(bounds); k++ {
+		bottom := Round(bounds[k], pricePrecision)
+		if bottom >= top {
+			return nil, fmt.Errorf("spread %f - %f is too narrow for %d parts with price precision %d",
+				baseSpread.BottomPrice, baseSpread.TopPrice, len(bounds)-1, pricePrecision)
+		}
+		res = append(res, &Spread{TopPrice: top, BottomPrice: bottom})
+		top = bottom
+	}
+	return res, nil
+}
@@ -0,0 +1,60 @@
+package exchange_models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDivisionStrategies(t *testing.T) {
+	base := &Spread{TopPrice: 60, BottomPrice: 50}
+	tests := []struct {
+		name     string
+		strategy DivisionStrategy
+		parts    int
+		want     []float64 // нижние границы частей
+		wantErr  bool
+	}{
+		{name: "equal", strategy: EqualDivision{}, parts: 4, want: []float64{57.5, 55, 52.5, 50}},
+		{name: "geometric", strategy: GeometricDivision{Ratio: 2}, parts: 3, want: []float64{58.57, 55.71, 50}},
+		{name: "fibonacci", strategy: FibonacciDivision{}, parts: 4, want: []float64{58.57, 57.14, 54.29, 50}},
+		{name: "log price", strategy: LogPriceDivision{}, parts: 2, want: []float64{54.77, 50}},
+		{name: "volatility", strategy: VolatilityDivision{Volatility: 0.01}, parts: 3, want: []float64{59.4, 57.55, 50}},
+		{name: "high volatility", strategy: VolatilityDivision{Volatility: 0.1}, parts: 3, want: []float64{54, 52.25, 50}},
+		{name: "single part", strategy: EqualDivision{}, parts: 1, want: []float64{50}},
+		{name: "too narrow", strategy: EqualDivision{}, parts: 2000, wantErr: true},
+		{name: "no parts", strategy: FibonacciDivision{}, parts: 0, wantErr: true},
+		{name: "bad ratio", strategy: GeometricDivision{}, parts: 3, wantErr: true},
+		{name: "volatility above spread", strategy: VolatilityDivision{Volatility: 0.2}, parts: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts, err := tt.strategy.Divide(base, tt.parts, 2)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, parts, len(tt.want))
+			top := base.TopPrice
+			for idx, part := range parts {
+				assert.Equal(t, top, part.TopPrice)
+				assert.Equal(t, tt.want[idx], part.BottomPrice)
+				assert.Greater(t, part.Length(), 0.0)
+				top = part.BottomPrice
+			}
+		})
+	}
+}
+
+func TestVolatilityDivision_Scales(t *testing.T) {
+	base := &Spread{TopPrice: 60, BottomPrice: 50}
+	low, err := VolatilityDivision{Volatility: 0.01}.Divide(base, 4, 2)
+	assert.NoError(t, err)
+	high, err := VolatilityDivision{Volatility: 0.05}.Divide(base, 4, 2)
+	assert.NoError(t, err)
+	assert.NotEqual(t, low, high)
+	// верхняя часть равна движению за один шаг
+	assert.InDelta(t, 0.6, low[0].Length(), 1e-9)
+	assert.InDelta(t, 3.0, high[0].Length(), 1e-9)
+}
@@ -0,0 +1,400 @@
+package exchange_models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+type ExecutionAlgo string
+
+var (
+	TWAP ExecutionAlgo = "TWAP" // равные части через равные промежутки времени
+	VWAP ExecutionAlgo = "VWAP" // части пропорциональны объему торгов в то же время прошлых дней
+	POV  ExecutionAlgo = "POV"  // доля от объема торгов за предыдущий интервал
+)
+
+type ExecutionConfig struct {
+	Symbol        SymbolInfo
+	Algo          ExecutionAlgo
+	Side          Side
+	BaseAmount    float64       // объем родительского ордера
+	LimitPrice    float64       // худшая допустимая цена, 0 - без ограничения
+	Duration      time.Duration // время исполнения
+	Slices        int           // количество интервалов
+	Aggressive    bool          // дочерние ордера по лучшей цене другой стороны, иначе по лучшей цене своей
+	Participation float64       // доля от объема торгов для POV
+	ProfileDays   int           // сколько прошлых дней берется для профиля VWAP, по умолчанию 1
+	MinNotional   float64       // дочерние ордера меньше этого объема в quote не выставляются
+}
+
+type ExecutionProgress struct {
+	Target      float64
+	Filled      float64 // исполнение, подтвержденное биржей
+	FilledQuote float64
+	Unconfirmed float64 // неисполненный остаток пропавших дочерних ордеров, когда коннектор без OrderLookup
+	Children    int     // выставлено дочерних ордеров
+	Slice       int     // номер текущего интервала
+}
+
+// Remaining объем, который еще можно выставить. Неподтвержденный остаток не выставляется заново,
+// чтобы не исполнить больше цели
+func (p *ExecutionProgress) Remaining() float64 {
+	return p.Target - p.Filled - p.Unconfirmed
+}
+
+func (p *ExecutionProgress) AveragePrice() float64 {
+	if p.Filled == 0 {
+		return 0
+	}
+	return p.FilledQuote / p.Filled
+}
+
+// Execution исполняет родительский ордер дочерними лимитными ордерами. Перед каждым интервалом
+// недоисполненные дочерние ордера отменяются, и выставляется новый на разницу между
+// плановым и исполненным объемом. Для пропавших с биржи и отмененных дочерних ордеров
+// исполненный объем уточняется через FinalFilled. Без OrderLookup у отмененного ордера
+// засчитывается исполнение по последней сверке, а остаток пропавшего уходит в Unconfirmed
+type Execution struct {
+	connector Connector
+	config    *ExecutionConfig
+	children  *ClassicNet
+
+	mu       sync.Mutex
+	progress ExecutionProgress
+}
+
+func NewExecution(c Connector, config *ExecutionConfig) (*Execution, error) {
+	switch {
+	case config.Side != Buy && config.Side != Sell:
+		return nil, fmt.Errorf("unknown side %q", config.Side)
+	case config.BaseAmount <= 0:
+		return nil, fmt.Errorf("base amount must be positive, got %f", config.BaseAmount)
+	case config.Slices <= 0:
+		return nil, fmt.Errorf("slices amount must be positive, got %d", config.Slices)
+	case config.Duration <= 0:
+		return nil, fmt.Errorf("duration must be positive, got %s", config.Duration)
+	}
+	switch config.Algo {
+	case TWAP, VWAP:
+	case POV:
+		if config.Participation <= 0 || config.Participation > 1 {
+			return nil, fmt.Errorf("participation must be between 0 and 1, got %f", config.Participation)
+		}
+	default:
+		return nil, fmt.Errorf("unknown execution algo %q", config.Algo)
+	}
+	config = configCopy(config)
+	if config.ProfileDays <= 0 {
+		config.ProfileDays = 1
+	}
+	e := &Execution{connector: c, config: config, children: NewEmptyClassicNet()}
+	e.progress.Target = Round(config.BaseAmount, config.Symbol.BasePrecision)
+	return e, nil
+}
+
+func (e *Execution) Progress() ExecutionProgress {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.progress
+}
+
+// Run исполняет ордер до конца Duration, полного исполнения или отмены ctx.
+// В конце недоисполненные дочерние ордера отменяются
+func (e *Execution) Run(ctx context.Context) (ExecutionProgress, error) {
+	start := time.Now()
+	interval := e.config.Duration / time.Duration(e.config.Slices)
+	var weights []float64
+	if e.config.Algo == VWAP {
+		var err error
+		if weights, err = e.volumeProfile(start, interval); err != nil {
+			return e.Progress(), err
+		}
+	}
+	var cumulative float64
+	lastCheck := start
+	for slice := 0; slice < e.config.Slices; slice++ {
+		if err := e.refresh(); err != nil {
+			err = errors.Join(err, e.cancelChildren())
+			return e.Progress(), err
+		}
+		if err := e.cancelChildren(); err != nil {
+			return e.Progress(), err
+		}
+		progress := e.Progress()
+		if progress.Remaining() <= precisionEps(e.config.Symbol.BasePrecision) {
+			break
+		}
+		var target float64
+		switch e.config.Algo {
+		case TWAP:
+			target = progress.Target * float64(slice+1) / float64(e.config.Slices)
+		case VWAP:
+			cumulative += weights[slice]
+			target = progress.Target * cumulative
+		case POV:
+			now := time.Now()
+			volume, err := e.marketVolume(lastCheck, now)
+			if err != nil {
+				return e.Progress(), err
+			}
+			lastCheck = now
+			target = progress.Filled + volume*e.config.Participation
+		}
+		if slice == e.config.Slices-1 && e.config.Algo != POV {
+			target = progress.Target
+		}
+		e.mu.Lock()
+		e.progress.Slice = slice + 1
+		e.mu.Unlock()
+		amount := Floor(math.Min(target, progress.Target)-progress.Filled-progress.Unconfirmed, e.config.Symbol.BasePrecision)
+		if amount > 0 {
+			if err := e.postChild(amount); err != nil {
+				err = errors.Join(err, e.cancelChildren())
+				return e.Progress(), err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return e.finish()
+		case <-time.After(time.Until(start.Add(interval * time.Duration(slice+1)))):
+		}
+	}
+	return e.finish()
+}
+
+func (e *Execution) finish() (ExecutionProgress, error) {
+	err := errors.Join(e.refresh(), e.cancelChildren())
+	return e.Progress(), err
+}
+
+// volumeProfile доли объема торгов по интервалам исполнения в то же время суток прошлых дней.
+// Без сделок в истории профиль равномерный
+func (e *Execution) volumeProfile(start time.Time, interval time.Duration) ([]float64, error) {
+	s := e.config.Symbol
+	day := 24 * time.Hour
+	from := start.Add(-day * time.Duration(e.config.ProfileDays))
+	deals, err := e.connector.DealHistory(s.Base, s.Quote, from.UnixMilli(), start.Add(e.config.Duration-day).UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("deal history: %w", err)
+	}
+	weights := make([]float64, e.config.Slices)
+	var total float64
+	for _, deal := range deals {
+		if deal.Time.Before(from) || !deal.Time.Before(start) {
+			continue
+		}
+		// смещение сделки от начала исполнения с точностью до суток
+		offset := (deal.Time.Sub(start)%day + day) % day
+		if offset >= e.config.Duration {
+			continue
+		}
+		slice := int(offset / interval)
+		if slice >= len(weights) {
+			slice = len(weights) - 1
+		}
+		volume := deal.BuyAmount + deal.SellAmount
+		weights[slice] += volume
+		total += volume
+	}
+	for idx := range weights {
+		if total == 0 {
+			weights[idx] = 1 / float64(len(weights))
+		} else {
+			weights[idx] /= total
+		}
+	}
+	return weights, nil
+}
+
+func (e *Execution) marketVolume(from, till time.Time) (float64, error) {
+	s := e.config.Symbol
+	deals, err := e.connector.DealHistory(s.Base, s.Quote, from.UnixMilli(), till.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("deal history: %w", err)
+	}
+	var volume float64
+	for _, deal := range deals {
+		if !deal.Time.Before(from) && deal.Time.Before(till) {
+			volume += deal.BuyAmount + deal.SellAmount
+		}
+	}
+	return volume, nil
+}
+
+func (e *Execution) postChild(amount float64) error {
+	s := e.config.Symbol
+	bestBid, bestAsk, err := e.connector.BestBidBestAsk(s.Base, s.Quote)
+	if err != nil {
+		return err
+	}
+	// пассивная покупка встает на лучшую покупку, агрессивная забирает лучшую продажу
+	price := bestBid
+	if e.config.Side == Buy && e.config.Aggressive || e.config.Side == Sell && !e.config.Aggressive {
+		price = bestAsk
+	}
+	if price <= 0 {
+		return nil
+	}
+	if e.config.LimitPrice > 0 {
+		// цена хуже предельной: интервал пропускается, объем переходит в следующий
+		if e.config.Side == Buy && price > e.config.LimitPrice || e.config.Side == Sell && price < e.config.LimitPrice {
+			return nil
+		}
+	}
+	if amount*price < e.config.MinNotional {
+		return nil
+	}
+	id, err := e.connector.PostLimitOrder(s.Base, s.Quote, e.config.Side, amount, price, s.BasePrecision, s.PricePrecision)
+	if err != nil {
+		return fmt.Errorf("post child %f at %f: %w", amount, price, err)
+	}
+	order, err := NewNetOrder(&NetOrderConfig{
+		Symbol:       symbol(s.Base, s.Quote),
+		Id:           id,
+		Side:         e.config.Side,
+		OrderType:    Limit,
+		Status:       New,
+		Price:        Round(price, s.PricePrecision),
+		BaseAmount:   amount,
+		CreationDate: time.Now().UTC(),
+		BasePrec:     s.BasePrecision,
+		PricePrec:    s.PricePrecision,
+	})
+	if err != nil {
+		return err
+	}
+	e.children.InsertOrder(order)
+	e.mu.Lock()
+	e.progress.Children++
+	e.mu.Unlock()
+	return nil
+}
+
+// refresh учитывает исполнения дочерних ордеров по открытым ордерам биржи
+func (e *Execution) refresh() error {
+	if e.children.Len(Buy)+e.children.Len(Sell) == 0 {
+		return nil
+	}
+	s := e.config.Symbol
+	diff, err := ReconcileWithExchange(e.connector, e.children, s.Base, s.Quote, s.BasePrecision, s.PricePrecision)
+	if err != nil {
+		return fmt.Errorf("reconcile: %w", err)
+	}
+	var errs []error
+	for _, fill := range diff.PartiallyFilled {
+		var fillErr error
+		_, err = e.children.UpdateOrder(fill.Order.ID(), func(order *NetOrder) {
+			fillErr = order.AddFilledAmount(fill.FilledAmount - order.FilledAmount())
+			order.SetStatus(PartiallyFilled)
+		})
+		if err = errors.Join(err, fillErr); err != nil {
+			errs = append(errs, fmt.Errorf("child %s: %w", fill.Order.ID(), err))
+		}
+	}
+	for _, fill := range diff.Filled {
+		errs = append(errs, e.closeChild(fill.Order.ID(), fill.Order.BaseAmount()))
+	}
+	if len(diff.Missing) == 0 {
+		return errors.Join(errs...)
+	}
+	// пропавший ордер мог исполниться, а мог быть снят в обход нас
+	final, err := FinalFilled(e.connector, s.Base, s.Quote, diff.Missing)
+	switch {
+	case errors.Is(err, ErrFilledUnknown):
+		for _, order := range diff.Missing {
+			errs = append(errs, e.closeChild(order.ID(), order.FilledAmount()))
+			e.addUnconfirmed(order.UnfilledAmount())
+		}
+	case err != nil:
+		errs = append(errs, fmt.Errorf("missing children: %w", err))
+	default:
+		for _, order := range diff.Missing {
+			errs = append(errs, e.closeChild(order.ID(), final[order.ID()]))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (e *Execution) cancelChildren() error {
+	s := e.config.Symbol
+	failed := make(map[string]error)
+	cancelled := make([]*NetOrder, 0)
+	for _, side := range []Side{Buy, Sell} {
+		for _, order := range e.children.Orders(side) {
+			if err := e.connector.CancelOrder(order.ID(), s.Base, s.Quote); err != nil {
+				failed[order.ID()] = err
+				continue
+			}
+			cancelled = append(cancelled, order)
+		}
+	}
+	var errs []error
+	if len(cancelled) > 0 {
+		// между последним refresh и отменой ордер мог исполниться еще
+		final, err := FinalFilled(e.connector, s.Base, s.Quote, cancelled)
+		if err != nil && !errors.Is(err, ErrFilledUnknown) {
+			errs = append(errs, fmt.Errorf("cancelled children: %w", err))
+		}
+		for _, order := range cancelled {
+			filled, ok := final[order.ID()]
+			if !ok {
+				filled = order.FilledAmount()
+			}
+			errs = append(errs, e.closeChild(order.ID(), filled))
+		}
+	}
+	if len(failed) == 0 {
+		return errors.Join(errs...)
+	}
+	// ордер мог исполниться перед отменой, тогда его уже нет на бирже
+	errs = append(errs, e.refresh())
+	for id, err := range failed {
+		if _, ok := e.children.OrderByID(id); ok {
+			errs = append(errs, fmt.Errorf("cancel child %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// closeChild закрывает дочерний ордер в сети с итоговым исполнением filled и засчитывает его
+func (e *Execution) closeChild(id string, filled float64) error {
+	var fillErr error
+	event, err := e.children.UpdateOrder(id, func(order *NetOrder) {
+		if amount := filled - order.FilledAmount(); amount > 0 {
+			fillErr = order.AddFilledAmount(amount)
+		}
+		status := Filled
+		if order.UnfilledAmount() > precisionEps(order.BasePrecision()) {
+			status = Cancelled
+			if order.FilledAmount() > 0 {
+				status = CancelledNotFully
+			}
+		}
+		order.SetStatus(status)
+	})
+	if err != nil {
+		return fmt.Errorf("close child %s: %w", id, err)
+	}
+	e.addFilled(event.Order.FilledAmount(), event.Order.Price())
+	if fillErr != nil {
+		return fmt.Errorf("close child %s: %w", id, fillErr)
+	}
+	return nil
+}
+
+func (e *Execution) addUnconfirmed(amount float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.progress.Unconfirmed = Round(e.progress.Unconfirmed+amount, e.config.Symbol.BasePrecision)
+}
+
+func (e *Execution) addFilled(amount, price float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.progress.Filled = Round(e.progress.Filled+amount, e.config.Symbol.BasePrecision)
+	e.progress.FilledQuote += amount * price
+}
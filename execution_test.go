@@ -0,0 +1,195 @@
+package exchange_models
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fillingConnector сразу исполняет часть каждого выставленного ордера
+// и отдает ордера по id и после закрытия
+type fillingConnector struct {
+	*mockConnector
+	fillRatio    float64
+	fillOnCancel float64 // доля, исполняющаяся между последней сверкой и отменой
+	posted       []float64
+	orders       map[string]*NetOrder
+}
+
+var _ OrderLookup = (*fillingConnector)(nil)
+
+func (c *fillingConnector) PostLimitOrder(base, quote string, side Side, baseAmount, price float64, basePrecision, pricePrecision int) (string, error) {
+	id, err := c.mockConnector.PostLimitOrder(base, quote, side, baseAmount, price, basePrecision, pricePrecision)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.posted = append(c.posted, baseAmount)
+	order := c.openOrders[len(c.openOrders)-1]
+	if c.orders == nil {
+		c.orders = make(map[string]*NetOrder)
+	}
+	c.orders[id] = order
+	if c.fillRatio >= 1 {
+		order.filledAmount = order.BaseAmount()
+		c.openOrders = c.openOrders[:len(c.openOrders)-1]
+	} else {
+		order.filledAmount = Floor(baseAmount*c.fillRatio, basePrecision)
+	}
+	return id, nil
+}
+
+func (c *fillingConnector) CancelOrder(orderId, base, quote string) error {
+	c.mu.Lock()
+	if order, ok := c.orders[orderId]; ok && c.fillOnCancel > 0 {
+		order.filledAmount = math.Min(order.BaseAmount(), Round(order.filledAmount+order.BaseAmount()*c.fillOnCancel, order.BasePrecision()))
+	}
+	c.mu.Unlock()
+	return c.mockConnector.CancelOrder(orderId, base, quote)
+}
+
+func (c *fillingConnector) OrderByID(orderId, base, quote string, basePrecision, pricePrecision int) (*NetOrder, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	order, ok := c.orders[orderId]
+	if !ok {
+		return nil, fmt.Errorf("order %s not found", orderId)
+	}
+	copied := *order
+	return &copied, nil
+}
+
+func newTestExecution(t *testing.T, c Connector, algo ExecutionAlgo) *Execution {
+	e, err := NewExecution(c, &ExecutionConfig{
+		Symbol:        SymbolInfo{Base: "SDFA", Quote: "USDT", BasePrecision: 3, PricePrecision: 2},
+		Algo:          algo,
+		Side:          Buy,
+		BaseAmount:    2,
+		Duration:      40 * time.Millisecond,
+		Slices:        4,
+		Aggressive:    true,
+		Participation: 0.5,
+	})
+	assert.NoError(t, err)
+	return e
+}
+
+func TestExecution_TWAP(t *testing.T) {
+	c := &fillingConnector{mockConnector: newMockConnector(), fillRatio: 1}
+	c.bestBid, c.bestAsk = 99, 101
+	progress, err := newTestExecution(t, c, TWAP).Run(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0.5, 0.5, 0.5, 0.5}, c.posted)
+	assert.Equal(t, 2.0, progress.Filled)
+	assert.Equal(t, 101.0, progress.AveragePrice())
+	assert.Equal(t, 4, progress.Children)
+}
+
+func TestExecution_PartialFills(t *testing.T) {
+	c := &fillingConnector{mockConnector: newMockConnector(), fillRatio: 0.5}
+	c.bestBid, c.bestAsk = 99, 101
+	progress, err := newTestExecution(t, c, TWAP).Run(context.Background())
+	assert.NoError(t, err)
+	// каждый следующий ордер добирает недоисполненное
+	assert.Equal(t, []float64{0.5, 0.75, 0.875, 0.938}, c.posted)
+	assert.Equal(t, 1.531, progress.Filled)
+	assert.Empty(t, c.openOrders)
+	assert.Len(t, c.cancelled, 4)
+}
+
+func TestExecution_VWAPProfile(t *testing.T) {
+	c := newMockConnector()
+	e := newTestExecution(t, c, VWAP)
+	e.config.Duration = 4 * time.Hour
+	start := time.Now()
+	yesterday := start.Add(-24 * time.Hour)
+	c.deals = []*Level{
+		{Price: 100, BuyAmount: 3, Time: yesterday.Add(30 * time.Minute)},
+		{Price: 100, SellAmount: 1, Time: yesterday.Add(3*time.Hour + 10*time.Minute)},
+		{Price: 100, BuyAmount: 100, Time: yesterday.Add(5 * time.Hour)}, // позже окна исполнения
+	}
+	weights, err := e.volumeProfile(start, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0.75, 0, 0, 0.25}, weights)
+
+	c.deals = nil
+	weights, err = e.volumeProfile(start, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0.25, 0.25, 0.25, 0.25}, weights)
+}
+
+func TestExecution_POV(t *testing.T) {
+	c := &fillingConnector{mockConnector: newMockConnector(), fillRatio: 1}
+	c.bestBid, c.bestAsk = 99, 101
+	e := newTestExecution(t, c, POV)
+	c.deals = []*Level{{Price: 100, BuyAmount: 0.4, Time: time.Now().Add(time.Millisecond)}}
+	progress, err := e.Run(context.Background())
+	assert.NoError(t, err)
+	// половина от 0.4, исполненных рынком за первый интервал
+	assert.Equal(t, []float64{0.2}, c.posted)
+	assert.Equal(t, 0.2, progress.Filled)
+}
+
+func TestExecution_Cancel(t *testing.T) {
+	c := &fillingConnector{mockConnector: newMockConnector(), fillRatio: 0}
+	c.bestBid, c.bestAsk = 99, 101
+	e := newTestExecution(t, c, TWAP)
+	e.config.Duration = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	progress, err := e.Run(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, progress.Filled)
+	assert.Empty(t, c.openOrders)
+	assert.Equal(t, 1, progress.Slice)
+}
+
+func TestExecution_MissingChild(t *testing.T) {
+	c := &lookupConnector{newMockConnector()}
+	e := newTestExecution(t, c, TWAP)
+	e.children.InsertOrder(newTestOrder("1", Buy, 101, 0.5, 0))
+	e.children.InsertOrder(newTestOrder("2", Buy, 100, 0.5, 0.1))
+	// первый исполнился, второй сняли в обход нас
+	c.closed["1"] = newTestOrder("1", Buy, 101, 0.5, 0.5).SetStatus(Filled)
+	c.closed["2"] = newTestOrder("2", Buy, 100, 0.5, 0.2).SetStatus(CancelledNotFully)
+
+	assert.NoError(t, e.refresh())
+	assert.Equal(t, 0.7, e.Progress().Filled)
+	assert.Zero(t, e.Progress().Unconfirmed)
+	assert.Equal(t, 0, e.children.Len(Buy))
+
+	// без OrderLookup засчитывается только известное исполнение, остаток не выставляется заново
+	e = newTestExecution(t, c.mockConnector, TWAP)
+	e.children.InsertOrder(newTestOrder("1", Buy, 101, 0.5, 0))
+	e.children.InsertOrder(newTestOrder("2", Buy, 100, 0.5, 0.1))
+	c.deals = []*Level{{Price: 100.5, SellAmount: 1, Time: time.Now().UTC()}}
+	assert.NoError(t, e.refresh())
+	progress := e.Progress()
+	assert.Equal(t, 0.1, progress.Filled)
+	assert.Equal(t, 0.9, progress.Unconfirmed)
+	assert.InDelta(t, 1, progress.Remaining(), 1e-9)
+	assert.Equal(t, 0, e.children.Len(Buy))
+}
+
+func TestExecution_FillBeforeCancel(t *testing.T) {
+	c := &fillingConnector{mockConnector: newMockConnector(), fillRatio: 0.5, fillOnCancel: 0.25}
+	c.bestBid, c.bestAsk = 99, 101
+	e := newTestExecution(t, c, TWAP)
+	assert.NoError(t, e.postChild(1))
+	assert.NoError(t, e.refresh())
+	child, ok := e.children.OrderByID("1")
+	assert.True(t, ok)
+	assert.Equal(t, 0.5, child.FilledAmount())
+	assert.Equal(t, PartiallyFilled, child.Status())
+	assert.NoError(t, e.cancelChildren())
+	assert.Equal(t, 0.75, e.Progress().Filled)
+	assert.Equal(t, 75.75, e.Progress().FilledQuote)
+}
@@ -1,8 +1,9 @@
 module github.com/sutapurachina/exchange-models
 
-go 1.21.3
+go 1.23
 
 require (
+	github.com/gorilla/websocket v1.5.0
 	github.com/stretchr/testify v1.8.4
 	github.com/sutapurachina/azbit-go-sdk v0.0.0-20240620131257-170de28fd70a
 	github.com/sutapurachina/go-p2pb2b v0.0.0-20231101205238-416ab66a206c
@@ -10,7 +11,6 @@ require (
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/gorilla/websocket v1.5.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,373 @@
+package exchange_models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+type GridConfig struct {
+	ExName          ExchangeName
+	Symbol          SymbolInfo
+	Range           *Spread
+	Levels          int              // количество цен сетки вместе с краями диапазона
+	Strategy        DivisionStrategy // деление диапазона на шаги, по умолчанию EqualDivision
+	OrderBaseAmount float64
+	StatePath       string        // файл состояния, пустой - состояние не сохраняется
+	Interval        time.Duration // период проверки для Run
+}
+
+// GridCycle завершенный цикл: покупка и продажа на соседних уровнях сетки
+type GridCycle struct {
+	OpenId    string    `json:"open_id"`
+	CloseId   string    `json:"close_id"`
+	BuyPrice  float64   `json:"buy_price"`
+	SellPrice float64   `json:"sell_price"`
+	Amount    float64   `json:"amount"`
+	Profit    float64   `json:"profit"` // в quote
+	Time      time.Time `json:"time"`
+}
+
+type gridOrder struct {
+	Order     *NetOrderConfig `json:"order"`
+	Level     int             `json:"level"`
+	OpenPrice float64         `json:"open_price"` // цена открывающего ордера, 0 если ордер сам открывает цикл
+}
+
+type gridState struct {
+	Orders  []*gridOrder      `json:"orders"`
+	Cycles  []*GridCycle      `json:"cycles"`
+	Profit  float64           `json:"profit"`
+	Missing []*NetOrderConfig `json:"missing,omitempty"`
+}
+
+// GridBot держит покупки ниже текущей цены и продажи выше нее на уровнях сетки.
+// Когда ордер исполняется, на соседнем уровне выставляется встречный ордер с PreviousId исполненного.
+// Биржа отдает только открытые ордера, поэтому исполнение пропавшего ордера подтверждается
+// через OrderLookup коннектора в ConfirmFills. Неподтвержденные ордера уходят в Missing без встречных ордеров
+type GridBot struct {
+	connector Connector
+	config    *GridConfig
+	prices    []float64 // цены уровней по возрастанию
+
+	mu      sync.Mutex
+	net     *ClassicNet
+	orders  map[string]*gridOrder
+	cycles  []*GridCycle
+	profit  float64
+	missing []*NetOrder
+}
+
+// NewGridBot создает бота и загружает состояние из StatePath, если файл есть
+func NewGridBot(c Connector, config *GridConfig) (*GridBot, error) {
+	if config.Levels < 2 {
+		return nil, fmt.Errorf("grid needs at least 2 levels, got %d", config.Levels)
+	}
+	if config.OrderBaseAmount <= 0 {
+		return nil, fmt.Errorf("order base amount must be positive, got %f", config.OrderBaseAmount)
+	}
+	if config.Range == nil {
+		return nil, errors.New("grid range is not set")
+	}
+	config = configCopy(config)
+	if config.Strategy == nil {
+		config.Strategy = EqualDivision{}
+	}
+	if config.Interval <= 0 {
+		config.Interval = 5 * time.Second
+	}
+	parts, err := config.Strategy.Divide(config.Range, config.Levels-1, config.Symbol.PricePrecision)
+	if err != nil {
+		return nil, err
+	}
+	prices := make([]float64, 0, config.Levels)
+	prices = append(prices, parts[0].TopPrice)
+	for _, part := range parts {
+		prices = append(prices, part.BottomPrice)
+	}
+	sort.Float64s(prices)
+	b := &GridBot{
+		connector: c,
+		config:    config,
+		prices:    prices,
+		net:       NewEmptyClassicNet(),
+		orders:    make(map[string]*gridOrder),
+	}
+	if err = b.load(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Prices цены уровней сетки по возрастанию
+func (b *GridBot) Prices() []float64 {
+	return append([]float64(nil), b.prices...)
+}
+
+func (b *GridBot) Net() *ClassicNet {
+	return b.net
+}
+
+func (b *GridBot) Profit() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.profit
+}
+
+func (b *GridBot) Cycles() []*GridCycle {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*GridCycle(nil), b.cycles...)
+}
+
+// Missing ордера, пропавшие с биржи без подтвержденного исполнения: отмененные вручную,
+// биржей или другим процессом. Их уровни остаются пустыми до следующей сетки
+func (b *GridBot) Missing() []*NetOrder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*NetOrder(nil), b.missing...)
+}
+
+// Run вызывает Step раз в Interval до отмены ctx. Ордера при остановке остаются на бирже,
+// после перезапуска бот продолжит с сохраненного состояния
+func (b *GridBot) Run(ctx context.Context) error {
+	ticker := time.NewTicker(b.config.Interval)
+	defer ticker.Stop()
+	for {
+		if err := b.Step(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Step выставляет сетку, если ордеров еще нет, иначе ищет исполненные ордера
+// и выставляет встречные
+func (b *GridBot) Step() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.orders) == 0 {
+		if err := b.placeGrid(); err != nil {
+			return err
+		}
+		return b.save()
+	}
+	s := b.config.Symbol
+	diff, err := ReconcileWithExchange(b.connector, b.net, s.Base, s.Quote, s.BasePrecision, s.PricePrecision)
+	if err != nil {
+		return fmt.Errorf("reconcile: %w", err)
+	}
+	confirmed, unconfirmed, err := ConfirmFills(b.connector, s.Base, s.Quote, diff.Missing)
+	if err != nil {
+		return fmt.Errorf("confirm fills: %w", err)
+	}
+	filled := make([]*NetOrder, 0, len(diff.Filled)+len(confirmed))
+	for _, fill := range diff.Filled {
+		filled = append(filled, fill.Order)
+	}
+	filled = append(filled, confirmed...)
+	// неизвестные ордера не трогаем, расходящиеся по параметрам считаем нашими
+	if err = diff.Apply(b.net, false); err != nil {
+		return fmt.Errorf("reconcile: %w", err)
+	}
+	for _, mismatch := range diff.Mismatched {
+		if order, ok := b.orders[mismatch.Local.ID()]; ok {
+			order.Order = orderConfig(mismatch.Live)
+		}
+	}
+	for _, order := range unconfirmed {
+		delete(b.orders, order.ID())
+		b.missing = append(b.missing, order)
+	}
+	var errs []error
+	for _, order := range filled {
+		if err = b.onFilled(order); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(append(errs, b.save())...)
+}
+
+// CancelAll отменяет все ордера сетки, прибыль и циклы сохраняются.
+// Следующий Step выставит сетку заново
+func (b *GridBot) CancelAll() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var errs []error
+	for id := range b.orders {
+		if err := b.connector.CancelOrder(id, b.config.Symbol.Base, b.config.Symbol.Quote); err != nil {
+			errs = append(errs, fmt.Errorf("cancel %s: %w", id, err))
+			continue
+		}
+		b.net.RemoveOrder(id)
+		delete(b.orders, id)
+	}
+	return errors.Join(append(errs, b.save())...)
+}
+
+func (b *GridBot) placeGrid() error {
+	bestBid, bestAsk, err := b.connector.BestBidBestAsk(b.config.Symbol.Base, b.config.Symbol.Quote)
+	if err != nil {
+		return err
+	}
+	if bestBid <= 0 || bestAsk <= 0 {
+		return fmt.Errorf("order book %s is empty on one side", symbol(b.config.Symbol.Base, b.config.Symbol.Quote))
+	}
+	mid := (bestBid + bestAsk) / 2
+	for level, price := range b.prices {
+		switch {
+		case price < mid:
+			err = b.post(Buy, level, "", 0)
+		case price > mid:
+			err = b.post(Sell, level, "", 0)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *GridBot) post(side Side, level int, previousId string, openPrice float64) error {
+	s := b.config.Symbol
+	price := b.prices[level]
+	id, err := b.connector.PostLimitOrder(s.Base, s.Quote, side, b.config.OrderBaseAmount, price, s.BasePrecision, s.PricePrecision)
+	if err != nil {
+		return fmt.Errorf("post %s at %f: %w", side, price, err)
+	}
+	order, err := NewNetOrder(&NetOrderConfig{
+		ExName:       b.config.ExName,
+		Symbol:       symbol(s.Base, s.Quote),
+		Id:           id,
+		Side:         side,
+		OrderType:    Limit,
+		Status:       New,
+		Price:        price,
+		BaseAmount:   Round(b.config.OrderBaseAmount, s.BasePrecision),
+		PreviousId:   previousId,
+		CreationDate: time.Now().UTC(),
+		BasePrec:     s.BasePrecision,
+		PricePrec:    s.PricePrecision,
+	})
+	if err != nil {
+		return err
+	}
+	b.net.InsertOrder(order)
+	b.orders[id] = &gridOrder{Order: orderConfig(order), Level: level, OpenPrice: openPrice}
+	return nil
+}
+
+// onFilled записывает цикл, если исполнился закрывающий ордер, и выставляет встречный ордер
+// на соседнем уровне: после покупки продажу уровнем выше, после продажи покупку уровнем ниже
+func (b *GridBot) onFilled(order *NetOrder) error {
+	filled, ok := b.orders[order.ID()]
+	if !ok {
+		return nil
+	}
+	delete(b.orders, order.ID())
+	var openPrice float64
+	if filled.OpenPrice > 0 {
+		cycle := &GridCycle{
+			OpenId:  order.PreviousId(),
+			CloseId: order.ID(),
+			Amount:  order.BaseAmount(),
+			Time:    time.Now().UTC(),
+		}
+		if order.Side() == Sell {
+			cycle.BuyPrice, cycle.SellPrice = filled.OpenPrice, order.Price()
+		} else {
+			cycle.BuyPrice, cycle.SellPrice = order.Price(), filled.OpenPrice
+		}
+		cycle.Profit = (cycle.SellPrice - cycle.BuyPrice) * cycle.Amount
+		b.cycles = append(b.cycles, cycle)
+		b.profit += cycle.Profit
+	} else {
+		openPrice = order.Price()
+	}
+	level, side := filled.Level+1, Sell
+	if order.Side() == Sell {
+		level, side = filled.Level-1, Buy
+	}
+	if level < 0 || level >= len(b.prices) {
+		return nil
+	}
+	return b.post(side, level, order.ID(), openPrice)
+}
+
+func (b *GridBot) save() error {
+	if b.config.StatePath == "" {
+		return nil
+	}
+	state := &gridState{Orders: make([]*gridOrder, 0, len(b.orders)), Cycles: b.cycles, Profit: b.profit}
+	for _, order := range b.missing {
+		state.Missing = append(state.Missing, orderConfig(order))
+	}
+	for id, order := range b.orders {
+		if netOrder, ok := b.net.OrderByID(id); ok {
+			order.Order = orderConfig(netOrder)
+		}
+		state.Orders = append(state.Orders, order)
+	}
+	sort.Slice(state.Orders, func(i, j int) bool {
+		return state.Orders[i].Level < state.Orders[j].Level
+	})
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	// запись через временный файл, чтобы при падении не остался обрезанный файл состояния
+	tmp := b.config.StatePath + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("save grid state: %w", err)
+	}
+	if err = os.Rename(tmp, b.config.StatePath); err != nil {
+		return fmt.Errorf("save grid state: %w", err)
+	}
+	return nil
+}
+
+func (b *GridBot) load() error {
+	if b.config.StatePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(b.config.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load grid state: %w", err)
+	}
+	var state gridState
+	if err = json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("load grid state: %w", err)
+	}
+	for _, order := range state.Orders {
+		if order.Level < 0 || order.Level >= len(b.prices) {
+			return fmt.Errorf("load grid state: order %s level %d is out of grid", order.Order.Id, order.Level)
+		}
+		netOrder, err := NewNetOrder(order.Order)
+		if err != nil {
+			return err
+		}
+		b.net.InsertOrder(netOrder)
+		b.orders[netOrder.ID()] = order
+	}
+	for _, config := range state.Missing {
+		order, err := NewNetOrder(config)
+		if err != nil {
+			return err
+		}
+		b.missing = append(b.missing, order)
+	}
+	b.cycles, b.profit = state.Cycles, state.Profit
+	return nil
+}
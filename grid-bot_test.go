@@ -0,0 +1,117 @@
+package exchange_models
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fillMockOrder убирает ордер с заданной ценой из открытых и запоминает его исполненным
+func fillMockOrder(c *mockConnector, side Side, price float64) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for idx, order := range c.openOrders {
+		if order.Side() == side && order.Price() == price {
+			c.openOrders = append(c.openOrders[:idx], c.openOrders[idx+1:]...)
+			filled := order.clone().SetStatus(Filled)
+			filled.filledAmount = filled.BaseAmount()
+			c.closed[order.ID()] = filled
+			return order.ID()
+		}
+	}
+	return ""
+}
+
+func TestGridBot(t *testing.T) {
+	c := &lookupConnector{newMockConnector()}
+	c.bestBid, c.bestAsk = 99.9, 100.1
+	config := &GridConfig{
+		Symbol:          SymbolInfo{Base: "SDFA", Quote: "USDT", BasePrecision: 3, PricePrecision: 2},
+		Range:           &Spread{TopPrice: 105, BottomPrice: 95},
+		Levels:          11,
+		OrderBaseAmount: 0.5,
+		StatePath:       filepath.Join(t.TempDir(), "grid.json"),
+	}
+	bot, err := NewGridBot(c, config)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{95, 96, 97, 98, 99, 100, 101, 102, 103, 104, 105}, bot.Prices())
+
+	assert.NoError(t, bot.Step())
+	assert.Equal(t, 5, bot.Net().Len(Buy))
+	assert.Equal(t, 5, bot.Net().Len(Sell))
+
+	// покупка по 99 исполнилась: продажа по 100 связана с ней
+	buyId := fillMockOrder(c.mockConnector, Buy, 99)
+	assert.NoError(t, bot.Step())
+	sells := bot.Net().OrdersInRange(Sell, 100, 100)
+	assert.Len(t, sells, 1)
+	assert.Equal(t, buyId, sells[0].PreviousId())
+	assert.Empty(t, bot.Cycles())
+
+	// продажа закрыла цикл, покупка по 99 выставлена снова
+	sellId := fillMockOrder(c.mockConnector, Sell, 100)
+	assert.NoError(t, bot.Step())
+	assert.Len(t, bot.Cycles(), 1)
+	assert.Equal(t, buyId, bot.Cycles()[0].OpenId)
+	assert.Equal(t, sellId, bot.Cycles()[0].CloseId)
+	assert.InDelta(t, 0.5, bot.Profit(), 1e-9)
+	buys := bot.Net().OrdersInRange(Buy, 99, 99)
+	assert.Len(t, buys, 1)
+	assert.Equal(t, sellId, buys[0].PreviousId())
+
+	// продажа по 101 открывает цикл, закрывает его покупка по 100
+	fillMockOrder(c.mockConnector, Sell, 101)
+	assert.NoError(t, bot.Step())
+	fillMockOrder(c.mockConnector, Buy, 100)
+	assert.NoError(t, bot.Step())
+	assert.Len(t, bot.Cycles(), 2)
+	assert.Equal(t, 101.0, bot.Cycles()[1].SellPrice)
+	assert.InDelta(t, 1, bot.Profit(), 1e-9)
+
+	// покупку по 96 сняли на бирже без сделок: встречного ордера и цикла нет
+	assert.NoError(t, c.CancelOrder(bot.Net().OrdersInRange(Buy, 96, 96)[0].ID(), "SDFA", "USDT"))
+	assert.NoError(t, bot.Step())
+	assert.Len(t, bot.Missing(), 1)
+	assert.Equal(t, 96.0, bot.Missing()[0].Price())
+	assert.Empty(t, bot.Net().OrdersInRange(Sell, 97, 97))
+	assert.Empty(t, bot.Net().OrdersInRange(Buy, 96, 96))
+	assert.Len(t, bot.Cycles(), 2)
+	assert.InDelta(t, 1, bot.Profit(), 1e-9)
+
+	// после перезапуска состояние восстанавливается из файла
+	restarted, err := NewGridBot(c, config)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1, restarted.Profit(), 1e-9)
+	assert.Len(t, restarted.Cycles(), 2)
+	assert.Len(t, restarted.Missing(), 1)
+	assert.Equal(t, bot.Net().Len(Buy), restarted.Net().Len(Buy))
+	assert.Equal(t, bot.Net().Len(Sell), restarted.Net().Len(Sell))
+	sell, _ := restarted.Net().Best(Sell)
+	assert.Equal(t, 101.0, sell.Price())
+	assert.NoError(t, restarted.Step())
+	assert.Len(t, restarted.Cycles(), 2)
+
+	assert.NoError(t, restarted.CancelAll())
+	assert.Empty(t, c.openOrders)
+}
+
+func TestGridBot_NoOrderLookup(t *testing.T) {
+	c := newMockConnector()
+	c.bestBid, c.bestAsk = 99.9, 100.1
+	bot, err := NewGridBot(c, &GridConfig{
+		Symbol:          SymbolInfo{Base: "SDFA", Quote: "USDT", BasePrecision: 3, PricePrecision: 2},
+		Range:           &Spread{TopPrice: 105, BottomPrice: 95},
+		Levels:          11,
+		OrderBaseAmount: 0.5,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, bot.Step())
+
+	// без OrderLookup исполнение не подтвердить: ордер уходит в Missing без встречного
+	fillMockOrder(c, Buy, 99)
+	assert.NoError(t, bot.Step())
+	assert.Len(t, bot.Missing(), 1)
+	assert.Empty(t, bot.Net().OrdersInRange(Sell, 100, 100))
+	assert.Empty(t, bot.Cycles())
+}
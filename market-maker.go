@@ -0,0 +1,253 @@
+package exchange_models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+type MarketMakerConfig struct {
+	ExName              ExchangeName
+	Symbol              SymbolInfo
+	SpreadBps           float64 // расстояние от середины до ближайших ордеров
+	LevelStepBps        float64 // расстояние между соседними ордерами одной стороны
+	LevelsPerSide       int
+	OrderBaseAmount     float64         // объем ордера без перекоса
+	TargetBaseRatio     float64         // желаемая доля base в стоимости портфеля, по умолчанию 0.5
+	SkewFactor          float64         // насколько сильно перекос портфеля меняет объемы, 0 - не меняет
+	RefreshThresholdBps float64         // сдвиг середины, после которого ордера переставляются
+	MinNotional         float64         // ордера меньше этого объема в quote не выставляются
+	Interval            time.Duration   // период проверки для Run
+	OnError             func(err error) // ошибки Step в Run
+}
+
+// MarketMakerBot держит двустороннюю сеть вокруг середины стакана.
+// Объемы ордеров зависят от перекоса портфеля: при избытке base продажи крупнее покупок
+type MarketMakerBot struct {
+	connector Connector
+	config    *MarketMakerConfig
+	net       *ClassicNet
+
+	mu      sync.Mutex
+	lastMid float64
+}
+
+var _ TradingBot = (*MarketMakerBot)(nil)
+
+func NewMarketMakerBot(c Connector, config *MarketMakerConfig) (*MarketMakerBot, error) {
+	switch {
+	case config.LevelsPerSide <= 0:
+		return nil, fmt.Errorf("levels per side must be positive, got %d", config.LevelsPerSide)
+	case config.OrderBaseAmount <= 0:
+		return nil, fmt.Errorf("order base amount must be positive, got %f", config.OrderBaseAmount)
+	case config.SpreadBps <= 0:
+		return nil, fmt.Errorf("spread must be positive, got %f bps", config.SpreadBps)
+	case config.TargetBaseRatio < 0 || config.TargetBaseRatio > 1:
+		return nil, fmt.Errorf("target base ratio must be between 0 and 1, got %f", config.TargetBaseRatio)
+	}
+	config = configCopy(config)
+	if config.TargetBaseRatio == 0 {
+		config.TargetBaseRatio = 0.5
+	}
+	if config.Interval <= 0 {
+		config.Interval = 5 * time.Second
+	}
+	return &MarketMakerBot{connector: c, config: config, net: NewEmptyClassicNet()}, nil
+}
+
+// Net сеть выставленных ботом ордеров
+func (b *MarketMakerBot) Net() *ClassicNet {
+	return b.net
+}
+
+// PostLimitOrder выставляет ордер вне сети бота
+func (b *MarketMakerBot) PostLimitOrder(order Order) error {
+	s := b.config.Symbol
+	id, err := b.connector.PostLimitOrder(s.Base, s.Quote, order.Side(), order.BaseAmount(), order.Price(), order.BasePrecision(), order.PricePrecision())
+	if err != nil {
+		return err
+	}
+	order.SetID(id).SetStatus(New).SetCreationDate(time.Now().UTC())
+	return nil
+}
+
+func (b *MarketMakerBot) CancelOrder(order Order) error {
+	s := b.config.Symbol
+	if err := b.connector.CancelOrder(order.ID(), s.Base, s.Quote); err != nil {
+		return err
+	}
+	order.SetStatus(Cancelled).SetDeathDate(time.Now().UTC())
+	return nil
+}
+
+func (b *MarketMakerBot) GetBestBidAsk() (bestBid float64, bestAsk float64, err error) {
+	return b.connector.BestBidBestAsk(b.config.Symbol.Base, b.config.Symbol.Quote)
+}
+
+// Run вызывает Step раз в Interval до отмены ctx, затем отменяет ордера бота.
+// Ошибка Step уходит в OnError, следующий Step будет на следующем тике
+func (b *MarketMakerBot) Run(ctx context.Context) error {
+	ticker := time.NewTicker(b.config.Interval)
+	defer ticker.Stop()
+	for {
+		if err := b.Step(); err != nil && b.config.OnError != nil {
+			b.config.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return b.Shutdown()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Step сверяет сеть с биржей и переставляет ордера, если середина сдвинулась дальше порога
+// или часть ордеров исполнилась
+func (b *MarketMakerBot) Step() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.config.Symbol
+	diff, err := ReconcileWithExchange(b.connector, b.net, s.Base, s.Quote, s.BasePrecision, s.PricePrecision)
+	if err != nil {
+		return fmt.Errorf("reconcile: %w", err)
+	}
+	if err = diff.Apply(b.net, false); err != nil {
+		return fmt.Errorf("reconcile: %w", err)
+	}
+	bestBid, bestAsk, err := b.GetBestBidAsk()
+	if err != nil {
+		return err
+	}
+	if bestBid <= 0 || bestAsk <= 0 {
+		return fmt.Errorf("order book %s is empty on one side", symbol(s.Base, s.Quote))
+	}
+	mid := (bestBid + bestAsk) / 2
+	filled := len(diff.Filled) > 0 || len(diff.PartiallyFilled) > 0 || len(diff.Missing) > 0
+	if !filled && b.lastMid > 0 && b.net.Len(Buy)+b.net.Len(Sell) > 0 &&
+		math.Abs(mid-b.lastMid)/b.lastMid*10000 < b.config.RefreshThresholdBps {
+		return nil
+	}
+	desired, err := b.quotes(mid)
+	if err != nil {
+		return err
+	}
+	// отмены первыми: средства из старых ордеров нужны новым
+	plan, err := PlanNet(b.net, desired, &PlanConfig{CancelFirst: true})
+	if err != nil {
+		return err
+	}
+	if err = plan.Execute(b.connector, s.Base, s.Quote, b.net); err != nil {
+		return err
+	}
+	b.lastMid = mid
+	return nil
+}
+
+// Shutdown отменяет все ордера сети бота
+func (b *MarketMakerBot) Shutdown() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var errs []error
+	for _, side := range []Side{Buy, Sell} {
+		for _, order := range b.net.Orders(side) {
+			if err := b.connector.CancelOrder(order.ID(), b.config.Symbol.Base, b.config.Symbol.Quote); err != nil {
+				errs = append(errs, fmt.Errorf("cancel %s: %w", order.ID(), err))
+				continue
+			}
+			b.net.RemoveOrder(order.ID())
+			order.SetStatus(Cancelled).SetDeathDate(time.Now().UTC())
+		}
+	}
+	b.lastMid = 0
+	return errors.Join(errs...)
+}
+
+// skew отклонение доли base в портфеле от желаемой, от -1 до 1, и бюджеты сторон:
+// свободные средства плюс замороженные в ордерах сети бота, которые он может переставить.
+// Заморозка сверх ордеров бота, например в чужих ордерах, в бюджет не входит
+func (b *MarketMakerBot) skew(mid float64) (skew, baseBudget, quoteBudget float64, err error) {
+	s := b.config.Symbol
+	baseAvailable, baseFreeze, err := b.connector.CurrencyBalance(s.Base)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("%s balance: %w", s.Base, err)
+	}
+	quoteAvailable, quoteFreeze, err := b.connector.CurrencyBalance(s.Quote)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("%s balance: %w", s.Quote, err)
+	}
+	baseBudget = baseAvailable + math.Min(baseFreeze, b.frozen(Sell))
+	quoteBudget = quoteAvailable + math.Min(quoteFreeze, b.frozen(Buy))
+	baseTotal, quoteTotal := baseAvailable+baseFreeze, quoteAvailable+quoteFreeze
+	value := baseTotal*mid + quoteTotal
+	if value <= 0 {
+		return 0, baseBudget, quoteBudget, nil
+	}
+	skew = (baseTotal*mid/value - b.config.TargetBaseRatio) / math.Max(b.config.TargetBaseRatio, 1-b.config.TargetBaseRatio)
+	return skew, baseBudget, quoteBudget, nil
+}
+
+// frozen средства в неисполненной части ордеров стороны: quote у покупок, base у продаж
+func (b *MarketMakerBot) frozen(side Side) float64 {
+	var sum float64
+	for _, order := range b.net.Orders(side) {
+		if side == Buy {
+			sum += order.UnfilledAmount() * order.Price()
+		} else {
+			sum += order.UnfilledAmount()
+		}
+	}
+	return sum
+}
+
+// quotes желаемая сеть вокруг mid. Объемы сторон ограничены балансами
+func (b *MarketMakerBot) quotes(mid float64) (*ClassicNet, error) {
+	c := b.config
+	skew, baseBudget, quoteBudget, err := b.skew(mid)
+	if err != nil {
+		return nil, err
+	}
+	amount := map[Side]float64{
+		Buy:  c.OrderBaseAmount * math.Max(0, 1-c.SkewFactor*skew),
+		Sell: c.OrderBaseAmount * math.Max(0, 1+c.SkewFactor*skew),
+	}
+	budget := map[Side]float64{Buy: quoteBudget, Sell: baseBudget}
+	net := NewEmptyClassicNet()
+	for _, side := range []Side{Buy, Sell} {
+		for i := 0; i < c.LevelsPerSide; i++ {
+			offset := (c.SpreadBps + float64(i)*c.LevelStepBps) / 10000
+			var price float64
+			if side == Buy {
+				price = Floor(mid*(1-offset), c.Symbol.PricePrecision)
+			} else {
+				price = Ceil(mid*(1+offset), c.Symbol.PricePrecision)
+			}
+			size := Floor(amount[side], c.Symbol.BasePrecision)
+			cost := size
+			if side == Buy {
+				cost = size * price
+			}
+			if size <= 0 || price <= 0 || size*price < c.MinNotional || cost > budget[side] {
+				break
+			}
+			budget[side] -= cost
+			order, err := NewNetOrder(&NetOrderConfig{
+				ExName:     c.ExName,
+				Symbol:     symbol(c.Symbol.Base, c.Symbol.Quote),
+				Side:       side,
+				OrderType:  Limit,
+				Price:      price,
+				BaseAmount: size,
+				BasePrec:   c.Symbol.BasePrecision,
+				PricePrec:  c.Symbol.PricePrecision,
+			})
+			if err != nil {
+				return nil, err
+			}
+			net.insertOrder(order)
+		}
+	}
+	return net, nil
+}
@@ -0,0 +1,169 @@
+package exchange_models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMarketMaker(t *testing.T, c *mockConnector) *MarketMakerBot {
+	bot, err := NewMarketMakerBot(c, &MarketMakerConfig{
+		Symbol:              SymbolInfo{Base: "SDFA", Quote: "USDT", BasePrecision: 3, PricePrecision: 2},
+		SpreadBps:           20,
+		LevelStepBps:        10,
+		LevelsPerSide:       3,
+		OrderBaseAmount:     1,
+		SkewFactor:          0.5,
+		RefreshThresholdBps: 10,
+		Interval:            time.Millisecond,
+	})
+	assert.NoError(t, err)
+	return bot
+}
+
+func TestMarketMakerBot_Step(t *testing.T) {
+	c := newMockConnector()
+	c.bestBid, c.bestAsk = 99, 101
+	c.balances["SDFA"], c.balances["USDT"] = 10, 1000
+	bot := newTestMarketMaker(t, c)
+
+	assert.NoError(t, bot.Step())
+	assert.Len(t, c.openOrders, 6)
+	best, _ := bot.Net().Best(Buy)
+	assert.Equal(t, 99.8, best.Price())
+	best, _ = bot.Net().Best(Sell)
+	assert.Equal(t, 100.2, best.Price())
+	// портфель сбалансирован, перекоса нет
+	assert.Equal(t, 1.0, best.BaseAmount())
+
+	// середина сдвинулась меньше порога
+	c.bestBid, c.bestAsk = 99.05, 101.05
+	assert.NoError(t, bot.Step())
+	assert.Empty(t, c.cancelled)
+
+	// сдвиг больше порога и избыток base: продажи крупнее покупок
+	c.bestBid, c.bestAsk = 101, 103
+	c.balances["SDFA"] = 20
+	assert.NoError(t, bot.Step())
+	assert.Len(t, c.openOrders, 6)
+	sell, _ := bot.Net().Best(Sell)
+	buy, _ := bot.Net().Best(Buy)
+	assert.Equal(t, 102.21, sell.Price())
+	assert.Greater(t, sell.BaseAmount(), buy.BaseAmount())
+	assert.False(t, netCrossed(bot.Net().Orders(Buy), bot.Net().Orders(Sell)))
+
+	// исполненный ордер пропадает с биржи, бот выставляет его заново
+	c.mu.Lock()
+	c.openOrders = c.openOrders[1:]
+	c.mu.Unlock()
+	assert.NoError(t, bot.Step())
+	assert.Len(t, c.openOrders, 6)
+}
+
+func TestMarketMakerBot_Run(t *testing.T) {
+	c := newMockConnector()
+	c.bestBid, c.bestAsk = 99, 101
+	c.balances["SDFA"], c.balances["USDT"] = 10, 1000
+	bot := newTestMarketMaker(t, c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- bot.Run(ctx)
+	}()
+	assert.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.openOrders) == 6
+	}, time.Second, time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Empty(t, c.openOrders)
+	assert.Equal(t, 0, bot.Net().Len(Buy)+bot.Net().Len(Sell))
+}
+
+func TestMarketMakerBot_RunAfterError(t *testing.T) {
+	c := newMockConnector()
+	c.balances["SDFA"], c.balances["USDT"] = 10, 1000
+	bot := newTestMarketMaker(t, c)
+	errs := make(chan error, 1)
+	bot.config.OnError = func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- bot.Run(ctx)
+	}()
+	// пустой стакан: Step падает, Run продолжает работу
+	assert.Error(t, <-errs)
+	c.mu.Lock()
+	c.bestBid, c.bestAsk = 99, 101
+	c.mu.Unlock()
+	assert.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.openOrders) == 6
+	}, time.Second, time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+// opsConnector записывает порядок отмен и выставлений
+type opsConnector struct {
+	*mockConnector
+	ops []ActionType
+}
+
+func (c *opsConnector) PostLimitOrder(base, quote string, side Side, baseAmount, price float64, basePrecision, pricePrecision int) (string, error) {
+	c.ops = append(c.ops, PostAction)
+	return c.mockConnector.PostLimitOrder(base, quote, side, baseAmount, price, basePrecision, pricePrecision)
+}
+
+func (c *opsConnector) CancelOrder(orderId, base, quote string) error {
+	c.ops = append(c.ops, CancelAction)
+	return c.mockConnector.CancelOrder(orderId, base, quote)
+}
+
+func TestMarketMakerBot_Budget(t *testing.T) {
+	c := &opsConnector{mockConnector: newMockConnector()}
+	c.bestBid, c.bestAsk = 99, 101
+	// 500 USDT заморожено в чужих ордерах, свободных хватает на две покупки
+	c.balances["SDFA"], c.balances["USDT"] = 10, 200
+	c.freezes["USDT"] = 500
+	bot, err := NewMarketMakerBot(c, newTestMarketMaker(t, c.mockConnector).config)
+	assert.NoError(t, err)
+	assert.NoError(t, bot.Step())
+	assert.Equal(t, 2, bot.Net().Len(Buy))
+
+	// средства в ордерах бота доступны для перестановки, но считаются один раз
+	c.balances["USDT"] = 200 - bot.frozen(Buy)
+	c.freezes["USDT"] = 500 + bot.frozen(Buy)
+	c.bestBid, c.bestAsk = 101, 103
+	c.ops = nil
+	assert.NoError(t, bot.Step())
+	assert.Equal(t, 2, bot.Net().Len(Buy))
+	assert.LessOrEqual(t, bot.frozen(Buy), 200.0)
+	// старые ордера отменены до выставления новых
+	assert.Equal(t, []ActionType{CancelAction, CancelAction, CancelAction, CancelAction, CancelAction,
+		PostAction, PostAction, PostAction, PostAction, PostAction}, c.ops)
+}
+
+func TestMarketMakerBot_TradingBot(t *testing.T) {
+	c := newMockConnector()
+	c.bestBid, c.bestAsk = 99, 101
+	bot := newTestMarketMaker(t, c)
+
+	bid, ask, err := bot.GetBestBidAsk()
+	assert.NoError(t, err)
+	assert.Equal(t, 99.0, bid)
+	assert.Equal(t, 101.0, ask)
+	_, err = NewMarketMakerBot(c, &MarketMakerConfig{})
+	assert.Error(t, err)
+}
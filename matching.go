@@ -0,0 +1,429 @@
+package exchange_models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// SimOrder наш ордер на симулированной бирже
+type SimOrder struct {
+	*NetOrder
+	Base       string
+	Quote      string
+	QueueAhead float64 // объем чужих заявок перед нами на нашей цене
+}
+
+// SimFill исполнение нашего ордера на симулированной бирже
+type SimFill struct {
+	OrderID string
+	Symbol  string
+	Side    Side
+	Price   float64
+	Amount  float64
+	Fee     float64 // в quote
+	Taker   bool
+	Time    time.Time
+}
+
+// FillModel решает, сколько base нашего ордера исполняет рыночная сделка.
+// available - объем сделки, еще не отданный другим нашим ордерам
+type FillModel interface {
+	TradeFill(order *SimOrder, trade *Level, available float64) float64
+}
+
+// TouchFillModel сделка по нашей цене или лучше исполняет ордер на весь свой объем
+type TouchFillModel struct{}
+
+// CrossFillModel ордер исполняется только сделками строго лучше нашей цены
+type CrossFillModel struct{}
+
+// QueueFillModel сделки по нашей цене сначала съедают очередь перед нами,
+// сделки строго лучше нашей цены исполняют ордер сразу
+type QueueFillModel struct{}
+
+func (TouchFillModel) TradeFill(order *SimOrder, trade *Level, available float64) float64 {
+	if !tradeReaches(order, trade.Price, true) {
+		return 0
+	}
+	return math.Min(order.UnfilledAmount(), available)
+}
+
+func (CrossFillModel) TradeFill(order *SimOrder, trade *Level, available float64) float64 {
+	if !tradeReaches(order, trade.Price, false) {
+		return 0
+	}
+	return math.Min(order.UnfilledAmount(), available)
+}
+
+func (QueueFillModel) TradeFill(order *SimOrder, trade *Level, available float64) float64 {
+	if tradeReaches(order, trade.Price, false) {
+		return math.Min(order.UnfilledAmount(), available)
+	}
+	if trade.Price != order.Price() {
+		return 0
+	}
+	queued := math.Min(order.QueueAhead, available)
+	order.QueueAhead -= queued
+	return math.Min(order.UnfilledAmount(), available-queued)
+}
+
+// tradeReaches цена сделки дошла до нашего ордера: для покупки не выше нашей цены, для продажи не ниже
+func tradeReaches(order *SimOrder, price float64, inclusive bool) bool {
+	if inclusive && price == order.Price() {
+		return true
+	}
+	if order.Side() == Buy {
+		return price < order.Price()
+	}
+	return price > order.Price()
+}
+
+// bookLevelKey уровень стакана, с которого наши ордера уже забрали объем
+type bookLevelKey struct {
+	side  Side
+	price float64
+}
+
+type simBalance struct {
+	available float64
+	freeze    float64
+}
+
+// matchingEngine исполняет наши лимитные ордера по рыночным сделкам и стакану.
+// Общий для бэктеста и бумажной торговли
+type matchingEngine struct {
+	mu        sync.Mutex
+	fillModel FillModel
+	makerFee  float64
+	takerFee  float64
+	now       func() time.Time
+
+	nextId       int
+	orders       []*SimOrder          // открытые ордера в порядке выставления
+	closed       map[string]*SimOrder // исполненные и снятые ордера по id
+	books        map[string]*OrderBookSnapshot
+	consumed     map[string]map[bookLevelKey]float64 // объем уровней текущего стакана, забранный нашими ордерами
+	balances     map[string]*simBalance
+	fills        []*SimFill
+	posted       int
+	postedAmount float64
+}
+
+func newMatchingEngine(fillModel FillModel, makerFee, takerFee float64, now func() time.Time) *matchingEngine {
+	if fillModel == nil {
+		fillModel = QueueFillModel{}
+	}
+	return &matchingEngine{
+		fillModel: fillModel,
+		makerFee:  makerFee,
+		takerFee:  takerFee,
+		now:       now,
+		closed:    make(map[string]*SimOrder),
+		books:     make(map[string]*OrderBookSnapshot),
+		consumed:  make(map[string]map[bookLevelKey]float64),
+		balances:  make(map[string]*simBalance),
+	}
+}
+
+func (e *matchingEngine) deposit(currency string, amount float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.balance(currency).available += amount
+}
+
+func (e *matchingEngine) balance(currency string) *simBalance {
+	b, ok := e.balances[currency]
+	if !ok {
+		b = &simBalance{}
+		e.balances[currency] = b
+	}
+	return b
+}
+
+func (e *matchingEngine) currencyBalance(currency string) (available, freeze float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	b := e.balance(currency)
+	return b.available, b.freeze
+}
+
+// post выставляет ордер. Часть, пересекающаяся с текущим стаканом, сразу исполняется как taker
+func (e *matchingEngine) post(base, quote string, side Side, baseAmount, price float64, basePrecision, pricePrecision int) (string, error) {
+	baseAmount, price = Round(baseAmount, basePrecision), Round(price, pricePrecision)
+	if baseAmount <= 0 || price <= 0 {
+		return "", fmt.Errorf("invalid order: amount %f, price %f", baseAmount, price)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	currency, cost := base, baseAmount
+	if side == Buy {
+		currency, cost = quote, e.buyReserve(baseAmount, price)
+	}
+	b := e.balance(currency)
+	if b.available < cost-precisionEps(basePrecision+pricePrecision) {
+		return "", fmt.Errorf("%w: %s %f, need %f", ErrInsufficientBalance, currency, b.available, cost)
+	}
+	b.available -= cost
+	b.freeze += cost
+
+	e.nextId++
+	order, err := NewNetOrder(&NetOrderConfig{
+		Symbol:       symbol(base, quote),
+		Id:           fmt.Sprintf("%d", e.nextId),
+		Side:         side,
+		OrderType:    Limit,
+		Status:       New,
+		Price:        price,
+		BaseAmount:   baseAmount,
+		CreationDate: e.now(),
+		BasePrec:     basePrecision,
+		PricePrec:    pricePrecision,
+	})
+	if err != nil {
+		return "", err
+	}
+	simOrder := &SimOrder{NetOrder: order, Base: base, Quote: quote}
+	e.posted++
+	e.postedAmount += baseAmount
+	if book, ok := e.books[order.Symbol()]; ok {
+		opposite := Sell
+		if side == Sell {
+			opposite = Buy
+		}
+		consumed := e.consumed[order.Symbol()]
+		for _, level := range book.Levels(opposite) {
+			if simOrder.UnfilledAmount() <= 0 || !tradeReaches(simOrder, level.Price, true) {
+				break
+			}
+			key := bookLevelKey{side: opposite, price: level.Price}
+			amount := Floor(math.Min(simOrder.UnfilledAmount(), level.Amount-consumed[key]), basePrecision)
+			if amount > 0 {
+				e.fill(simOrder, amount, level.Price, true)
+				consumed[key] += amount
+			}
+		}
+		for _, level := range book.Levels(side) {
+			if level.Price == price {
+				simOrder.QueueAhead = level.Amount
+			}
+		}
+	}
+	if simOrder.UnfilledAmount() > 0 {
+		e.orders = append(e.orders, simOrder)
+	} else {
+		e.closed[order.ID()] = simOrder
+	}
+	return order.ID(), nil
+}
+
+func (e *matchingEngine) cancel(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for idx, order := range e.orders {
+		if order.ID() != id {
+			continue
+		}
+		e.orders = append(e.orders[:idx], e.orders[idx+1:]...)
+		currency, frozen := order.Base, order.UnfilledAmount()
+		if order.Side() == Buy {
+			currency, frozen = order.Quote, e.buyReserve(order.UnfilledAmount(), order.Price())
+		}
+		b := e.balance(currency)
+		b.freeze -= frozen
+		b.available += frozen
+		status := Cancelled
+		if order.FilledAmount() > 0 {
+			status = CancelledNotFully
+		}
+		order.SetStatus(status).SetDeathDate(e.now())
+		e.closed[id] = order
+		return nil
+	}
+	return fmt.Errorf("order %s not found", id)
+}
+
+// order копия открытого или закрытого ордера по id
+func (e *matchingEngine) order(id string) (*NetOrder, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, order := range e.orders {
+		if order.ID() == id {
+			return order.NetOrder.clone(), nil
+		}
+	}
+	if order, ok := e.closed[id]; ok {
+		return order.NetOrder.clone(), nil
+	}
+	return nil, fmt.Errorf("order %s not found", id)
+}
+
+// openOrders копии открытых ордеров пары
+func (e *matchingEngine) openOrders(base, quote string) []*NetOrder {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	res := make([]*NetOrder, 0, len(e.orders))
+	for _, order := range e.orders {
+		if order.Symbol() == symbol(base, quote) {
+			res = append(res, order.NetOrder.clone())
+		}
+	}
+	return res
+}
+
+// onTrade исполняет наши ордера рыночной сделкой, начиная с лучших цен
+func (e *matchingEngine) onTrade(base, quote string, trade *Level) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	available := trade.BuyAmount + trade.SellAmount
+	for _, order := range e.sorted(symbol(base, quote)) {
+		if available <= 0 {
+			break
+		}
+		amount := Floor(e.fillModel.TradeFill(order, trade, available), order.BasePrecision())
+		if amount > 0 {
+			e.fill(order, amount, order.Price(), false)
+			available -= amount
+		}
+	}
+	e.removeDone()
+}
+
+// onBook запоминает стакан и исполняет наши ордера, через которые он прошел.
+// Очередь перед нами не может быть больше объема уровня в новом стакане.
+// Забранный нашими ордерами объем уровня помнится, пока уровень не изменится,
+// поэтому повторная загрузка того же стакана не исполняет его еще раз
+func (e *matchingEngine) onBook(base, quote string, book *OrderBookSnapshot) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sym := symbol(base, quote)
+	used := make(map[bookLevelKey]float64)
+	if prev, ok := e.books[sym]; ok {
+		for _, side := range []Side{Buy, Sell} {
+			amounts := make(map[float64]float64)
+			for _, level := range prev.Levels(side) {
+				amounts[level.Price] = level.Amount
+			}
+			for _, level := range book.Levels(side) {
+				key := bookLevelKey{side: side, price: level.Price}
+				if amount, ok := amounts[level.Price]; ok && amount == level.Amount && e.consumed[sym][key] > 0 {
+					used[key] = e.consumed[sym][key]
+				}
+			}
+		}
+	}
+	e.books[sym] = book
+	e.consumed[sym] = used
+	for _, order := range e.sorted(sym) {
+		opposite := Sell
+		if order.Side() == Sell {
+			opposite = Buy
+		}
+		for _, level := range book.Levels(opposite) {
+			if order.UnfilledAmount() <= 0 || !tradeReaches(order, level.Price, false) {
+				break
+			}
+			key := bookLevelKey{side: opposite, price: level.Price}
+			amount := Floor(math.Min(order.UnfilledAmount(), level.Amount-used[key]), order.BasePrecision())
+			if amount > 0 {
+				e.fill(order, amount, order.Price(), false)
+				used[key] += amount
+			}
+		}
+		queue := 0.0
+		for _, level := range book.Levels(order.Side()) {
+			if level.Price == order.Price() {
+				queue = level.Amount
+			}
+		}
+		order.QueueAhead = math.Min(order.QueueAhead, queue)
+	}
+	e.removeDone()
+}
+
+// sorted открытые ордера пары от лучшей цены, при равной цене раньше выставленный
+func (e *matchingEngine) sorted(sym string) []*SimOrder {
+	res := make([]*SimOrder, 0, len(e.orders))
+	for _, order := range e.orders {
+		if order.Symbol() == sym {
+			res = append(res, order)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Side() != res[j].Side() {
+			return res[i].Side() == Buy
+		}
+		if res[i].Side() == Buy {
+			return res[i].Price() > res[j].Price()
+		}
+		return res[i].Price() < res[j].Price()
+	})
+	return res
+}
+
+// buyReserve quote, замораживаемый под покупку: объем по цене ордера и наибольшая из комиссий
+func (e *matchingEngine) buyReserve(amount, price float64) float64 {
+	return amount * price * (1 + math.Max(e.makerFee, e.takerFee))
+}
+
+// fill исполняет amount по цене price. Комиссия в quote берется из резерва покупки,
+// разница между резервом и фактической стоимостью возвращается в доступный quote
+func (e *matchingEngine) fill(order *SimOrder, amount, price float64, taker bool) {
+	amount = Round(math.Min(amount, order.UnfilledAmount()), order.BasePrecision())
+	if amount <= 0 {
+		return
+	}
+	fee := e.makerFee
+	if taker {
+		fee = e.takerFee
+	}
+	quoteAmount := amount * price
+	base, quote := e.balance(order.Base), e.balance(order.Quote)
+	if order.Side() == Buy {
+		reserve := e.buyReserve(amount, order.Price())
+		quote.freeze -= reserve
+		quote.available += reserve - quoteAmount*(1+fee)
+		base.available += amount
+	} else {
+		base.freeze -= amount
+		quote.available += quoteAmount * (1 - fee)
+	}
+	_ = order.AddFilledAmount(amount)
+	order.SetStatus(PartiallyFilled)
+	if order.UnfilledAmount() <= 0 {
+		order.SetStatus(Filled).SetDeathDate(e.now())
+	}
+	e.fills = append(e.fills, &SimFill{
+		OrderID: order.ID(),
+		Symbol:  order.Symbol(),
+		Side:    order.Side(),
+		Price:   price,
+		Amount:  amount,
+		Fee:     quoteAmount * fee,
+		Taker:   taker,
+		Time:    e.now(),
+	})
+}
+
+func (e *matchingEngine) removeDone() {
+	open := e.orders[:0]
+	for _, order := range e.orders {
+		if order.UnfilledAmount() > 0 {
+			open = append(open, order)
+		} else {
+			e.closed[order.ID()] = order
+		}
+	}
+	e.orders = open
+}
+
+func (e *matchingEngine) fillsCopy() []*SimFill {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*SimFill(nil), e.fills...)
+}
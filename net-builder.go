@@ -0,0 +1,200 @@
+package exchange_models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Profile распределение объема сети по ордерам
+type Profile string
+
+var (
+	FlatProfile        Profile = "Flat"        // одинаковые ордера
+	LinearProfile      Profile = "Linear"      // объем линейно растет к середине спреда
+	GeometricProfile   Profile = "Geometric"   // каждый следующий от середины ордер в Ratio раз меньше
+	ExponentialProfile Profile = "Exponential" // объем убывает как exp(-Ratio * d), d - доля расстояния от середины
+)
+
+var ErrBelowMinNotional = errors.New("order quote amount is below min notional")
+
+// NetBuilderConfig параметры сети одной стороны. Задается ровно один из бюджетов:
+// BaseBudget или QuoteBudget
+type NetBuilderConfig struct {
+	ExName         ExchangeName
+	Symbol         string
+	Side           Side
+	Range          *Spread // цены ордеров, для покупок середина спреда сверху, для продаж снизу
+	BaseBudget     float64
+	QuoteBudget    float64
+	OrdersAmount   int
+	Profile        Profile
+	Ratio          float64 // для Geometric и Exponential, по умолчанию 1.5 и 2
+	BasePrecision  int
+	PricePrecision int
+	MinNotional    float64 // минимальный объем ордера в quote
+}
+
+type NetBuilder struct {
+	config *NetBuilderConfig
+}
+
+// NewNetBuilder проверяет конфиг и сохраняет его копию, поэтому изменения конфига
+// после создания на билдер не влияют
+func NewNetBuilder(config *NetBuilderConfig) (*NetBuilder, error) {
+	c := *config
+	if c.Range != nil {
+		spread := *c.Range
+		c.Range = &spread
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return &NetBuilder{config: &c}, nil
+}
+
+func (c *NetBuilderConfig) validate() error {
+	switch {
+	case c.Side != Buy && c.Side != Sell:
+		return fmt.Errorf("unknown side %q", c.Side)
+	case c.Range == nil || c.Range.Length() < 0 || c.Range.BottomPrice <= 0:
+		return errors.New("price range must be positive with top price not below bottom price")
+	case c.OrdersAmount <= 0:
+		return fmt.Errorf("orders amount must be positive, got %d", c.OrdersAmount)
+	case (c.BaseBudget > 0) == (c.QuoteBudget > 0):
+		return errors.New("exactly one of base and quote budgets must be set")
+	}
+	switch c.Profile {
+	case "", FlatProfile, LinearProfile, GeometricProfile, ExponentialProfile:
+	default:
+		return fmt.Errorf("unknown profile %q", c.Profile)
+	}
+	return nil
+}
+
+// Prices цены ордеров от ближайшей к середине спреда, шаг между ценами одинаковый
+func (b *NetBuilder) Prices() ([]float64, error) {
+	c := b.config
+	near, far := c.Range.TopPrice, c.Range.BottomPrice
+	if c.Side == Sell {
+		near, far = far, near
+	}
+	if c.OrdersAmount == 1 {
+		return []float64{Round(near, c.PricePrecision)}, nil
+	}
+	step := (far - near) / float64(c.OrdersAmount-1)
+	prices := make([]float64, c.OrdersAmount)
+	for i := range prices {
+		prices[i] = Round(near+step*float64(i), c.PricePrecision)
+		if i > 0 && prices[i] == prices[i-1] {
+			return nil, fmt.Errorf("price range %f - %f is too narrow for %d orders with price precision %d",
+				c.Range.BottomPrice, c.Range.TopPrice, c.OrdersAmount, c.PricePrecision)
+		}
+	}
+	return prices, nil
+}
+
+// weights доли объема ордеров в порядке Prices
+func (b *NetBuilder) weights(prices []float64) []float64 {
+	c := b.config
+	n := len(prices)
+	weights := make([]float64, n)
+	for i := range weights {
+		switch c.Profile {
+		case LinearProfile:
+			weights[i] = float64(n - i)
+		case GeometricProfile:
+			ratio := c.Ratio
+			if ratio <= 0 {
+				ratio = 1.5
+			}
+			weights[i] = math.Pow(ratio, -float64(i))
+		case ExponentialProfile:
+			ratio := c.Ratio
+			if ratio <= 0 {
+				ratio = 2
+			}
+			var d float64
+			if length := math.Abs(prices[n-1] - prices[0]); length > 0 {
+				d = math.Abs(prices[i]-prices[0]) / length
+			}
+			weights[i] = math.Exp(-ratio * d)
+		default:
+			weights[i] = 1
+		}
+	}
+	return weights
+}
+
+// Amounts объемы ордеров в base по ценам prices. Объемы округляются вниз,
+// остаток бюджета достается ордеру с наибольшей долей, так что сумма не превышает бюджет
+func (b *NetBuilder) Amounts(prices []float64) ([]float64, error) {
+	c := b.config
+	weights := b.weights(prices)
+	var weightsSum float64
+	for _, w := range weights {
+		weightsSum += w
+	}
+	amounts := make([]float64, len(prices))
+	var baseSum, quoteSum float64
+	for i, price := range prices {
+		share := weights[i] / weightsSum
+		if c.BaseBudget > 0 {
+			amounts[i] = Floor(c.BaseBudget*share, c.BasePrecision)
+		} else {
+			amounts[i] = Floor(c.QuoteBudget*share/price, c.BasePrecision)
+		}
+		baseSum += amounts[i]
+		quoteSum += amounts[i] * price
+	}
+	largest := 0
+	for i := range weights {
+		if weights[i] > weights[largest] {
+			largest = i
+		}
+	}
+	if c.BaseBudget > 0 {
+		amounts[largest] = Round(amounts[largest]+Floor(c.BaseBudget-baseSum+precisionEps(c.BasePrecision+1), c.BasePrecision), c.BasePrecision)
+	} else {
+		amounts[largest] = Round(amounts[largest]+Floor((c.QuoteBudget-quoteSum)/prices[largest], c.BasePrecision), c.BasePrecision)
+	}
+	for i, amount := range amounts {
+		if amount <= 0 || amount*prices[i] < c.MinNotional {
+			return nil, fmt.Errorf("%w: %f at %f, min notional %f", ErrBelowMinNotional, amount, prices[i], c.MinNotional)
+		}
+	}
+	return amounts, nil
+}
+
+// Build сеть из ордеров без id, готовая для PlanNet
+func (b *NetBuilder) Build() (*ClassicNet, error) {
+	if err := b.config.validate(); err != nil {
+		return nil, err
+	}
+	prices, err := b.Prices()
+	if err != nil {
+		return nil, err
+	}
+	amounts, err := b.Amounts(prices)
+	if err != nil {
+		return nil, err
+	}
+	net := NewEmptyClassicNet()
+	for i, price := range prices {
+		order, err := NewNetOrder(&NetOrderConfig{
+			ExName:     b.config.ExName,
+			Symbol:     b.config.Symbol,
+			Side:       b.config.Side,
+			OrderType:  Limit,
+			Price:      price,
+			BaseAmount: amounts[i],
+			BasePrec:   b.config.BasePrecision,
+			PricePrec:  b.config.PricePrecision,
+		})
+		if err != nil {
+			return nil, err
+		}
+		net.insertOrder(order)
+	}
+	return net, nil
+}
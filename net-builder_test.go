@@ -0,0 +1,91 @@
+package exchange_models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetBuilder_Build(t *testing.T) {
+	for _, profile := range []Profile{FlatProfile, LinearProfile, GeometricProfile, ExponentialProfile} {
+		builder, err := NewNetBuilder(&NetBuilderConfig{
+			Side:           Buy,
+			Range:          &Spread{TopPrice: 54.9, BottomPrice: 50},
+			BaseBudget:     10,
+			OrdersAmount:   8,
+			Profile:        profile,
+			BasePrecision:  3,
+			PricePrecision: 2,
+			MinNotional:    5,
+		})
+		assert.NoError(t, err)
+		net, err := builder.Build()
+		assert.NoError(t, err, profile)
+		orders := net.Orders(Buy)
+		assert.Len(t, orders, 8)
+		assert.Equal(t, 54.9, orders[0].Price())
+		assert.Equal(t, 50.0, orders[7].Price())
+		assert.InDelta(t, 10, net.BaseAmount(Buy), 1e-9, profile)
+		for i, order := range orders {
+			assert.Equal(t, order.BaseAmount(), Round(order.BaseAmount(), 3))
+			assert.Equal(t, order.Price(), Round(order.Price(), 2))
+			if i > 0 && profile != FlatProfile {
+				assert.LessOrEqual(t, order.BaseAmount(), orders[i-1].BaseAmount(), profile)
+			}
+		}
+	}
+}
+
+func TestNetBuilder_QuoteBudget(t *testing.T) {
+	builder, err := NewNetBuilder(&NetBuilderConfig{
+		Side:           Sell,
+		Range:          &Spread{TopPrice: 60, BottomPrice: 56},
+		QuoteBudget:    1000,
+		OrdersAmount:   5,
+		Profile:        LinearProfile,
+		BasePrecision:  3,
+		PricePrecision: 2,
+	})
+	assert.NoError(t, err)
+	net, err := builder.Build()
+	assert.NoError(t, err)
+	orders := net.Orders(Sell)
+	assert.Equal(t, []float64{56, 57, 58, 59, 60}, []float64{orders[0].Price(), orders[1].Price(), orders[2].Price(), orders[3].Price(), orders[4].Price()})
+	assert.LessOrEqual(t, net.QuoteAmount(Sell), 1000.0)
+	assert.Greater(t, net.QuoteAmount(Sell), 1000-orders[0].Price()*0.001)
+}
+
+func TestNetBuilder_Errors(t *testing.T) {
+	config := &NetBuilderConfig{
+		Side:           Buy,
+		Range:          &Spread{TopPrice: 54.9, BottomPrice: 54.88},
+		BaseBudget:     1,
+		OrdersAmount:   5,
+		BasePrecision:  3,
+		PricePrecision: 2,
+	}
+	builder, err := NewNetBuilder(config)
+	assert.NoError(t, err)
+	_, err = builder.Build()
+	assert.Error(t, err)
+
+	config.Range = &Spread{TopPrice: 55, BottomPrice: 50}
+	config.MinNotional = 20
+	builder, err = NewNetBuilder(config)
+	assert.NoError(t, err)
+	_, err = builder.Build()
+	assert.True(t, errors.Is(err, ErrBelowMinNotional))
+
+	// билдер работает со своей копией конфига
+	config.MinNotional = 0
+	config.Range.BottomPrice = 54.99
+	config.OrdersAmount = 0
+	_, err = builder.Build()
+	assert.True(t, errors.Is(err, ErrBelowMinNotional))
+
+	config.OrdersAmount = 5
+	config.QuoteBudget = 100
+	_, err = NewNetBuilder(config)
+	assert.Error(t, err)
+}
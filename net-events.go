@@ -0,0 +1,138 @@
+package exchange_models
+
+import "sync"
+
+type NetEventType string
+
+var (
+	OrderInserted NetEventType = "Inserted"
+	OrderRemoved  NetEventType = "Removed"
+	OrderFilled   NetEventType = "Filled"
+	OrderRepriced NetEventType = "Repriced"
+	OrderUpdated  NetEventType = "Updated"
+	SpreadChanged NetEventType = "SpreadChanged"
+)
+
+// NetEvent изменение ордера в сети. Order - копия ордера после изменения, Old* - значения до изменения.
+// У SpreadChanged Order пустой, заполнены OldSpread и Spread в терминах ClassicNet.Spread
+type NetEvent struct {
+	Type            NetEventType
+	Order           *NetOrder
+	Removed         bool // ордер больше не в сети
+	OldPrice        float64
+	OldBaseAmount   float64
+	OldFilledAmount float64
+	OldSpread       float64
+	Spread          float64
+}
+
+// netSubscribers очередь событий и обработчики. События встают в очередь под n.mu,
+// поэтому порядок очереди совпадает с порядком изменений. Рассылает очередь одна горутина
+// за раз и без блокировок, так что обработчики могут читать сеть
+type netSubscribers struct {
+	mu       sync.Mutex // защищает поля ниже, не держится во время вызова обработчиков
+	lastId   int
+	handlers []*netHandler
+	queue    []*NetEvent
+	draining bool
+}
+
+type netHandler struct {
+	id     int
+	handle func(event *NetEvent)
+}
+
+func newNetSubscribers() *netSubscribers {
+	return &netSubscribers{handlers: make([]*netHandler, 0)}
+}
+
+// Subscribe регистрирует обработчик событий сети. Обработчики вызываются после изменения
+// по одному событию в порядке изменений, в горутине одного из писателей. Обработчик может
+// читать и менять сеть, события от его изменений придут следующими. События, уже ушедшие
+// в рассылку, могут прийти и после отписки. Возвращает функцию отписки
+func (n *ClassicNet) Subscribe(handler func(event *NetEvent)) (unsubscribe func()) {
+	n.subs.mu.Lock()
+	defer n.subs.mu.Unlock()
+	n.subs.lastId++
+	id := n.subs.lastId
+	n.subs.handlers = append(n.subs.handlers, &netHandler{id: id, handle: handler})
+	return func() {
+		n.subs.mu.Lock()
+		defer n.subs.mu.Unlock()
+		for idx, h := range n.subs.handlers {
+			if h.id == id {
+				n.subs.handlers = append(n.subs.handlers[:idx:idx], n.subs.handlers[idx+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// SubscribeChan события сети в канал. Если читатель не успевает, рассылка ждет его,
+// а изменения сети копятся в очереди. Отписка закрывает канал
+func (n *ClassicNet) SubscribeChan(buffer int) (events <-chan *NetEvent, unsubscribe func()) {
+	ch := make(chan *NetEvent, buffer)
+	done := make(chan struct{})
+	var sendMu sync.RWMutex
+	closed := false
+	remove := n.Subscribe(func(event *NetEvent) {
+		sendMu.RLock()
+		defer sendMu.RUnlock()
+		if closed {
+			return
+		}
+		select {
+		case ch <- event:
+		case <-done:
+		}
+	})
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			close(done)
+			remove()
+			sendMu.Lock()
+			closed = true
+			close(ch)
+			sendMu.Unlock()
+		})
+	}
+}
+
+// publish вызывается под n.mu и снимает его. События встают в очередь до снятия n.mu,
+// рассылаются уже без блокировки сети
+func (n *ClassicNet) publish(oldSpread float64, events ...*NetEvent) {
+	spread, _ := n.spread()
+	if spread != oldSpread {
+		events = append(events, &NetEvent{Type: SpreadChanged, OldSpread: oldSpread, Spread: spread})
+	}
+	n.subs.mu.Lock()
+	n.subs.queue = append(n.subs.queue, events...)
+	n.subs.mu.Unlock()
+	n.mu.Unlock()
+	n.subs.drain()
+}
+
+// drain рассылает очередь, если этим не занята другая горутина
+func (s *netSubscribers) drain() {
+	s.mu.Lock()
+	if s.draining {
+		s.mu.Unlock()
+		return
+	}
+	s.draining = true
+	for len(s.queue) > 0 {
+		event := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		handlers := append([]*netHandler(nil), s.handlers...)
+		s.mu.Unlock()
+		for _, h := range handlers {
+			h.handle(event)
+		}
+		s.mu.Lock()
+	}
+	s.queue = nil
+	s.draining = false
+	s.mu.Unlock()
+}
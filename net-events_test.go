@@ -0,0 +1,149 @@
+package exchange_models
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassicNet_Subscribe(t *testing.T) {
+	net := NewEmptyClassicNet()
+	events := make([]*NetEvent, 0)
+	unsubscribe := net.Subscribe(func(event *NetEvent) {
+		// обработчик может читать сеть
+		net.Len(Buy)
+		events = append(events, event)
+	})
+
+	net.InsertOrder(newTestOrder("1", Buy, 54, 1, 0))
+	assert.Len(t, events, 1)
+	assert.Equal(t, OrderInserted, events[0].Type)
+
+	net.InsertOrder(newTestOrder("2", Sell, 56, 1, 0))
+	assert.Len(t, events, 3)
+	assert.Equal(t, SpreadChanged, events[2].Type)
+	assert.Equal(t, 0.0, events[2].OldSpread)
+	assert.Equal(t, -2.0, events[2].Spread)
+
+	_, err := net.UpdateOrder("2", func(order *NetOrder) { order.SetPrice(55) })
+	assert.NoError(t, err)
+	assert.Len(t, events, 5)
+	assert.Equal(t, OrderRepriced, events[3].Type)
+	assert.Equal(t, -1.0, events[4].Spread)
+
+	_, err = net.UpdateOrder("1", func(order *NetOrder) { assert.NoError(t, order.AddFilledAmount(1)) })
+	assert.NoError(t, err)
+	assert.Equal(t, OrderFilled, events[5].Type)
+	assert.True(t, events[5].Removed)
+	assert.Equal(t, SpreadChanged, events[6].Type)
+
+	assert.True(t, net.RemoveOrder("2"))
+	assert.Equal(t, OrderRemoved, events[7].Type)
+	assert.Equal(t, 55.0, events[7].OldPrice)
+	assert.False(t, net.RemoveOrder("2"))
+	assert.Len(t, events, 8)
+
+	unsubscribe()
+	net.InsertOrder(newTestOrder("3", Buy, 54, 1, 0))
+	assert.Len(t, events, 8)
+}
+
+func TestClassicNet_SubscribeChan(t *testing.T) {
+	net := NewEmptyClassicNet()
+	events, unsubscribe := net.SubscribeChan(1)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		net.InsertOrder(newTestOrder("1", Buy, 54, 1, 0))
+		net.InsertOrder(newTestOrder("2", Buy, 53, 1, 0))
+		net.RemoveOrder("1")
+	}()
+	assert.Equal(t, "1", (<-events).Order.ID())
+	assert.Equal(t, "2", (<-events).Order.ID())
+	assert.Equal(t, OrderRemoved, (<-events).Type)
+	<-done
+
+	// отписка не ждет читателя, даже если канал заполнен
+	net.InsertOrder(newTestOrder("3", Buy, 53, 1, 0))
+	finished := make(chan struct{})
+	go func() {
+		net.InsertOrder(newTestOrder("4", Buy, 53, 1, 0))
+		close(finished)
+	}()
+	unsubscribe()
+	<-finished
+	unsubscribe()
+}
+
+func TestClassicNet_SubscribeConcurrent(t *testing.T) {
+	net := NewEmptyClassicNet()
+	var inserted atomic.Int64
+	net.Subscribe(func(event *NetEvent) {
+		// обработчик читает сеть, пока другие горутины ее меняют
+		net.Len(Buy)
+		net.Spread()
+		if event.Type == OrderInserted {
+			inserted.Add(1)
+		}
+	})
+	events, unsubscribe := net.SubscribeChan(0)
+	read := make(chan int)
+	go func() {
+		count := 0
+		for event := range events {
+			net.BaseAmount(Buy)
+			if event.Type == OrderInserted {
+				count++
+			}
+		}
+		read <- count
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					net.InsertOrder(newTestOrder(fmt.Sprintf("%d-%d", w, i), Buy, 50+float64(i%10), 1, 0))
+				}
+			}()
+		}
+		wg.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("deadlock: writers did not finish")
+	}
+	// писатель, начавший рассылку, возвращается только после опустошения очереди
+	assert.Equal(t, int64(400), inserted.Load())
+	unsubscribe()
+	assert.Equal(t, 400, <-read)
+}
+
+func TestClassicNet_SubscribeOrder(t *testing.T) {
+	net := NewEmptyClassicNet()
+	ids := make([]string, 0)
+	net.Subscribe(func(event *NetEvent) {
+		if event.Type != OrderInserted {
+			return
+		}
+		ids = append(ids, event.Order.ID())
+		// изменение сети из обработчика дает событие после текущего
+		if event.Order.ID() == "1" {
+			net.InsertOrder(newTestOrder("2", Buy, 53, 1, 0))
+		}
+	})
+	net.InsertOrder(newTestOrder("1", Buy, 54, 1, 0))
+	net.InsertOrder(newTestOrder("3", Buy, 52, 1, 0))
+	assert.Equal(t, []string{"1", "2", "3"}, ids)
+}
@@ -2,7 +2,10 @@ package exchange_models
 
 import (
 	"fmt"
+	"iter"
 	"math"
+	"sync"
+	"time"
 )
 
 type Net interface {
@@ -17,230 +20,350 @@ type Net interface {
 	Print()
 }
 
+// ClassicNet безопасна для использования из нескольких горутин.
+// Ордера каждой стороны лежат в orderTree, поэтому вставка и удаление стоят O(log n),
+// поиск по id - O(1), объем на диапазоне цен - O(log n).
+// Чтение и события отдают копии ордеров, а UpdateOrder меняет копию и подменяет ею ордер в дереве,
+// так что уже отданные ордера сеть не меняет
 type ClassicNet struct {
 	Net
-	BuyOrders  []*NetOrder
-	SellOrders []*NetOrder
+	mu    sync.RWMutex
+	buy   *orderTree
+	sell  *orderTree
+	index map[string][]*treeNode // у ордеров из стакана id бывает пустым и повторяется
+	subs  *netSubscribers
 }
 
 func NewEmptyClassicNet() *ClassicNet {
-	buyOrders := make([]*NetOrder, 0, 1)
-	sellOrders := make([]*NetOrder, 0, 1)
 	return &ClassicNet{
-		BuyOrders:  buyOrders,
-		SellOrders: sellOrders,
+		buy:   newOrderTree(Buy),
+		sell:  newOrderTree(Sell),
+		index: make(map[string][]*treeNode),
+		subs:  newNetSubscribers(),
 	}
 }
 
+// Orders копия списка ордеров стороны: покупки по убыванию цены, продажи по возрастанию,
+// при равной цене раньше идет ордер, добавленный раньше
 func (n *ClassicNet) Orders(side Side) []*NetOrder {
-	if side == Buy {
-		return n.BuyOrders
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return cloneOrders(n.tree(side).orders())
+}
+
+func cloneOrders(orders []*NetOrder) []*NetOrder {
+	for idx, order := range orders {
+		orders[idx] = order.clone()
 	}
-	return n.SellOrders
+	return orders
 }
 
-func (n *ClassicNet) BaseAmountFromTillLevel(side Side, fromPrice, tillLevel float64) float64 {
+// BuyOrders копия покупок по убыванию цены. Раньше это было полем сети.
+//
+// Deprecated: используйте Orders(Buy)
+func (n *ClassicNet) BuyOrders() []*NetOrder {
+	return n.Orders(Buy)
+}
+
+// SellOrders копия продаж по возрастанию цены. Раньше это было полем сети.
+//
+// Deprecated: используйте Orders(Sell)
+func (n *ClassicNet) SellOrders() []*NetOrder {
+	return n.Orders(Sell)
+}
+
+func (n *ClassicNet) tree(side Side) *orderTree {
 	if side == Buy {
-		if len(n.BuyOrders) == 0 {
-			return 0
-		}
+		return n.buy
+	}
+	return n.sell
+}
 
-		fromPrice, tillLevel = math.Max(fromPrice, tillLevel), math.Min(fromPrice, tillLevel)
-		firstIdx := 0
-		firstIdxFound := false
-		for idx, order := range n.BuyOrders {
-			if order.Price() <= fromPrice && !firstIdxFound {
-				firstIdx = idx
-				firstIdxFound = true
-			}
-			if order.Price() < tillLevel && firstIdxFound {
-				return BaseAmount(n.BuyOrders[firstIdx:idx], n.BuyOrders[0].BasePrecision())
-			}
-		}
-		if firstIdxFound {
-			return BaseAmount(n.BuyOrders[firstIdx:], n.BuyOrders[0].BasePrecision())
-		}
-		return 0
+// Snapshot независимая копия сети вместе с ордерами. Все значения, прочитанные из копии,
+// относятся к одному моменту
+func (n *ClassicNet) Snapshot() *ClassicNet {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	snapshot := NewEmptyClassicNet()
+	for _, side := range []Side{Buy, Sell} {
+		n.tree(side).walk(func(order *NetOrder) bool {
+			snapshot.insertOrder(order.clone())
+			return true
+		})
 	}
+	return snapshot
+}
 
-	if len(n.SellOrders) == 0 {
+func (n *ClassicNet) BaseAmountFromTillLevel(side Side, fromPrice, tillLevel float64) float64 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	tree := n.tree(side)
+	if tree.len() == 0 {
 		return 0
 	}
-	fromPrice, tillLevel = math.Min(fromPrice, tillLevel), math.Max(fromPrice, tillLevel)
-	firstIdx := 0
-	firstIdxFound := false
-	for idx, order := range n.SellOrders {
-		if order.Price() >= fromPrice && !firstIdxFound {
-			firstIdx = idx
-			firstIdxFound = true
-		}
-		if order.Price() > tillLevel && firstIdxFound {
-			return BaseAmount(n.SellOrders[firstIdx:idx], n.SellOrders[0].BasePrecision())
-		}
-	}
-	if firstIdxFound {
-		return BaseAmount(n.SellOrders[firstIdx:], n.SellOrders[0].BasePrecision())
+	fromPrice, tillLevel = orderRange(side, fromPrice, tillLevel)
+	base, _, _ := tree.rangeSum(fromPrice, tillLevel)
+	return Round(base, tree.first().BasePrecision())
+}
+
+func (n *ClassicNet) QuoteAmountFromTillLevel(side Side, fromPrice, tillLevel float64) float64 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	tree := n.tree(side)
+	if tree.len() == 0 {
+		return 0
 	}
-	return 0
+	fromPrice, tillLevel = orderRange(side, fromPrice, tillLevel)
+	_, quote, _ := tree.rangeSum(fromPrice, tillLevel)
+	first := tree.first()
+	return Round(quote, first.BasePrecision()+first.PricePrecision())
 }
 
-func (n *ClassicNet) BaseAmount(side Side) float64 {
+// OrdersInRange ордера стороны с ценами между from и to включительно в порядке Orders
+func (n *ClassicNet) OrdersInRange(side Side, from, to float64) []*NetOrder {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	from, to = orderRange(side, from, to)
+	res := make([]*NetOrder, 0)
+	n.tree(side).walkRange(from, to, func(order *NetOrder) bool {
+		res = append(res, order.clone())
+		return true
+	})
+	return res
+}
+
+// orderRange упорядочивает границы так, что from ближе к лучшей цене стороны
+func orderRange(side Side, from, to float64) (float64, float64) {
 	if side == Buy {
-		if len(n.BuyOrders) == 0 {
-			return 0
-		}
-		return BaseAmount(n.BuyOrders, n.BuyOrders[0].BasePrecision())
+		return math.Max(from, to), math.Min(from, to)
 	}
-	if len(n.SellOrders) == 0 {
-		return 0
+	return math.Min(from, to), math.Max(from, to)
+}
+
+func (n *ClassicNet) OrderByID(id string) (*NetOrder, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	nd, found := n.findOrder(id)
+	if !found {
+		return nil, false
 	}
-	return BaseAmount(n.SellOrders, n.SellOrders[0].BasePrecision())
+	return nd.order.clone(), true
 }
 
-func (n *ClassicNet) QuoteAmount(side Side) float64 {
-	if side == Buy {
-		if len(n.BuyOrders) == 0 {
-			return 0
+// Best ордер с лучшей ценой стороны: самая дорогая покупка или самая дешевая продажа
+func (n *ClassicNet) Best(side Side) (*NetOrder, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	best := n.tree(side).first()
+	if best == nil {
+		return nil, false
+	}
+	return best.clone(), true
+}
+
+func (n *ClassicNet) Len(side Side) int {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.tree(side).len()
+}
+
+// All итератор по ордерам стороны в порядке Orders. Обходится копия списка,
+// поэтому внутри цикла сеть можно менять
+func (n *ClassicNet) All(side Side) iter.Seq2[int, *NetOrder] {
+	return func(yield func(int, *NetOrder) bool) {
+		for idx, order := range n.Orders(side) {
+			if !yield(idx, order) {
+				return
+			}
 		}
-		return QuoteAmount(n.BuyOrders)
 	}
-	if len(n.SellOrders) == 0 {
+}
+
+func (n *ClassicNet) BaseAmount(side Side) float64 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.baseAmount(side)
+}
+
+func (n *ClassicNet) baseAmount(side Side) float64 {
+	tree := n.tree(side)
+	if tree.len() == 0 {
 		return 0
 	}
-	return QuoteAmount(n.SellOrders)
+	return Round(tree.root.base, tree.first().BasePrecision())
+}
+
+func (n *ClassicNet) QuoteAmount(side Side) float64 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.quoteAmount(side)
+}
+
+func (n *ClassicNet) quoteAmount(side Side) float64 {
+	return n.tree(side).root.getQuote()
 }
 
-// WeightedAveragePrice todo проходится по списку один раз
 func (n *ClassicNet) WeightedAveragePrice(side Side) float64 {
-	base := n.BaseAmount(side)
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	base := n.baseAmount(side)
 	if base > 0 {
-		return n.QuoteAmount(side) / base
+		return n.quoteAmount(side) / base
 	}
 	return 0
 }
 
 func (n *ClassicNet) Spread() (length, ratio float64) {
-	if len(n.BuyOrders) == 0 || len(n.SellOrders) == 0 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.spread()
+}
+
+func (n *ClassicNet) spread() (length, ratio float64) {
+	bestBuy, bestSell := n.buy.first(), n.sell.first()
+	if bestBuy == nil || bestSell == nil {
 		return 0, 0
 	}
-	length = n.BuyOrders[0].Price() - n.SellOrders[0].Price()
-	ratio = length / n.BuyOrders[0].Price()
+	length = bestBuy.Price() - bestSell.Price()
+	ratio = length / bestBuy.Price()
 	return
 }
 
 func (n *ClassicNet) InsertOrder(order *NetOrder) {
-	if order.Side() == Buy {
-		if len(n.BuyOrders) == 0 {
-			n.insertOrderInTheEnd(order)
-			return
-		}
-		for idx, activeOrder := range n.BuyOrders {
-			if order.Price() > activeOrder.Price() {
-				if idx == 0 {
-					n.insertOrderInTheBeginning(order)
-					return
-				}
-				tmp := make([]*NetOrder, idx)
-				copy(tmp, n.BuyOrders[0:idx])
-				tmp = append(tmp, order)
-				n.BuyOrders = append(tmp, n.BuyOrders[idx:]...)
-				return
-			}
-		}
-		n.insertOrderInTheEnd(order)
-		return
-	}
-	if len(n.SellOrders) == 0 {
-		n.insertOrderInTheEnd(order)
-		return
-	}
-	for idx, activeOrder := range n.SellOrders {
-		if order.Price() < activeOrder.Price() {
-			if idx == 0 {
-				n.insertOrderInTheBeginning(order)
-				return
-			}
-			tmp := make([]*NetOrder, idx)
-			copy(tmp, n.SellOrders[0:idx])
-			tmp = append(tmp, order)
-			n.SellOrders = append(tmp, n.SellOrders[idx:]...)
-			return
-		}
+	n.mu.Lock()
+	spread, _ := n.spread()
+	n.insertOrder(order)
+	n.publish(spread, &NetEvent{Type: OrderInserted, Order: order.clone()})
+}
 
-	}
-	n.insertOrderInTheEnd(order)
-	return
+func (n *ClassicNet) insertOrder(order *NetOrder) {
+	nd := n.tree(order.Side()).insert(order)
+	n.index[order.ID()] = append(n.index[order.ID()], nd)
 }
 
-func (n *ClassicNet) insertOrderInTheEnd(order *NetOrder) {
-	if order.Side() == Buy {
-		n.BuyOrders = append(n.BuyOrders, order)
-		return
+func (n *ClassicNet) RemoveOrder(id string) bool {
+	n.mu.Lock()
+	nd, found := n.findOrder(id)
+	if !found {
+		n.mu.Unlock()
+		return false
 	}
-	n.SellOrders = append(n.SellOrders, order)
+	spread, _ := n.spread()
+	n.removeNode(nd)
+	n.publish(spread, &NetEvent{
+		Type:            OrderRemoved,
+		Order:           nd.order.clone(),
+		Removed:         true,
+		OldPrice:        nd.price,
+		OldBaseAmount:   nd.ownBase,
+		OldFilledAmount: nd.order.FilledAmount(),
+	})
+	return true
 }
 
-func (n *ClassicNet) insertOrderInTheBeginning(order *NetOrder) {
-	if order.Side() == Buy {
-		n.BuyOrders = append([]*NetOrder{order}, n.BuyOrders...)
-		return
+// UpdateOrder меняет ордер внутри сети. При изменении цены ордер встает в конец очереди
+// на новой цене, полностью исполненный или отмененный ордер удаляется из сети.
+// Исполненному ордеру без финального статуса ставится Filled
+func (n *ClassicNet) UpdateOrder(id string, mutate func(order *NetOrder)) (*NetEvent, error) {
+	n.mu.Lock()
+	spread, _ := n.spread()
+	event, err := n.updateOrder(id, mutate)
+	if err != nil {
+		n.mu.Unlock()
+		return nil, err
 	}
-	n.SellOrders = append([]*NetOrder{order}, n.SellOrders...)
+	n.publish(spread, event)
+	return event, nil
 }
 
-func (n *ClassicNet) RemoveOrder(id string) bool {
-	side, index, found := n.findOrder(id)
+func (n *ClassicNet) updateOrder(id string, mutate func(order *NetOrder)) (*NetEvent, error) {
+	nd, found := n.findOrder(id)
 	if !found {
-		return false
+		return nil, fmt.Errorf("order %s not found in net", id)
 	}
-	n.deleteElementByIdx(side, index)
-	return true
-}
+	event := &NetEvent{
+		Type:            OrderUpdated,
+		OldPrice:        nd.price,
+		OldBaseAmount:   nd.ownBase,
+		OldFilledAmount: nd.order.FilledAmount(),
+	}
+	oldSide := nd.order.Side()
+	n.removeNode(nd)
+	// читатели могли получить прежний ордер, поэтому меняется копия
+	order := nd.order.clone()
+	mutate(order)
+	nd.order = order
 
-func (n *ClassicNet) deleteElementByIdx(side Side, idx int) {
-	var orders *[]*NetOrder
-	if side == Buy {
-		orders = &n.BuyOrders
-	} else {
-		orders = &n.SellOrders
+	switch {
+	case order.Price() != event.OldPrice:
+		event.Type = OrderRepriced
+	case order.FilledAmount() > event.OldFilledAmount:
+		event.Type = OrderFilled
 	}
-	if idx == 0 {
-		*orders = (*orders)[1:]
-		return
+	if order.UnfilledAmount() <= 0 && !finalStatus(order.Status()) {
+		order.SetStatus(Filled)
+	}
+	removed := finalStatus(order.Status())
+	if removed && order.DeathDate().IsZero() {
+		order.SetDeathDate(time.Now().UTC())
 	}
-	ret := make([]*NetOrder, 0, 1)
-	ret = append(ret, (*orders)[:idx]...)
-	*orders = append(ret, (*orders)[idx+1:]...)
+	event.Order = order.clone()
+	if removed {
+		event.Removed = true
+		if event.Type == OrderUpdated {
+			event.Type = OrderRemoved
+		}
+		return event, nil
+	}
+	if order.Price() == event.OldPrice && order.Side() == oldSide {
+		n.tree(order.Side()).reinsert(nd)
+		n.index[order.ID()] = append(n.index[order.ID()], nd)
+		return event, nil
+	}
+	n.insertOrder(order)
+	return event, nil
+}
+
+func finalStatus(status OrderStatus) bool {
+	return status == Filled || status == Cancelled || status == CancelledNotFully || status == Closed
 }
 
-func (n *ClassicNet) findOrder(id string) (side Side, index int, found bool) {
-	for idx, order := range n.SellOrders {
-		if id == order.ID() {
-			side = Sell
-			index = idx
-			found = true
-			return
+func (n *ClassicNet) removeNode(nd *treeNode) {
+	n.tree(nd.order.Side()).remove(nd)
+	id := nd.order.ID()
+	nodes := n.index[id]
+	for idx, indexed := range nodes {
+		if indexed == nd {
+			nodes = append(nodes[:idx], nodes[idx+1:]...)
+			break
 		}
 	}
-	for idx, order := range n.BuyOrders {
-		if id == order.ID() {
-			side = Buy
-			index = idx
-			found = true
-			return
-		}
+	if len(nodes) == 0 {
+		delete(n.index, id)
+		return
 	}
-	found = false
-	return
+	n.index[id] = nodes
+}
+
+func (n *ClassicNet) findOrder(id string) (*treeNode, bool) {
+	nodes, ok := n.index[id]
+	if !ok {
+		return nil, false
+	}
+	return nodes[0], true
 }
 
 func (n *ClassicNet) Print() {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
 	fmt.Printf("Net:\n\n\n")
-	for i := len(n.SellOrders) - 1; i >= 0; i-- {
-		n.SellOrders[i].Print()
+	sellOrders := n.sell.orders()
+	for i := len(sellOrders) - 1; i >= 0; i-- {
+		sellOrders[i].Print()
 	}
 	fmt.Println("----------")
-	for _, o := range n.BuyOrders {
-		o.Print()
-	}
+	n.buy.walk(func(order *NetOrder) bool {
+		order.Print()
+		return true
+	})
 }
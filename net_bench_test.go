@@ -0,0 +1,126 @@
+package exchange_models
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// sliceNet прежняя реализация ClassicNet на отсортированных слайсах, оставлена для сравнения
+type sliceNet struct {
+	BuyOrders  []*NetOrder
+	SellOrders []*NetOrder
+}
+
+func (n *sliceNet) InsertOrder(order *NetOrder) {
+	orders := &n.SellOrders
+	if order.Side() == Buy {
+		orders = &n.BuyOrders
+	}
+	for idx, activeOrder := range *orders {
+		if order.Side() == Buy && order.Price() > activeOrder.Price() ||
+			order.Side() == Sell && order.Price() < activeOrder.Price() {
+			tmp := make([]*NetOrder, idx)
+			copy(tmp, (*orders)[0:idx])
+			tmp = append(tmp, order)
+			*orders = append(tmp, (*orders)[idx:]...)
+			return
+		}
+	}
+	*orders = append(*orders, order)
+}
+
+func (n *sliceNet) RemoveOrder(id string) bool {
+	for _, orders := range []*[]*NetOrder{&n.SellOrders, &n.BuyOrders} {
+		for idx, order := range *orders {
+			if order.ID() == id {
+				ret := make([]*NetOrder, 0, 1)
+				ret = append(ret, (*orders)[:idx]...)
+				*orders = append(ret, (*orders)[idx+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (n *sliceNet) BaseAmountFromTillLevel(side Side, fromPrice, tillLevel float64) float64 {
+	fromPrice, tillLevel = math.Max(fromPrice, tillLevel), math.Min(fromPrice, tillLevel)
+	firstIdx := 0
+	firstIdxFound := false
+	for idx, order := range n.BuyOrders {
+		if order.Price() <= fromPrice && !firstIdxFound {
+			firstIdx = idx
+			firstIdxFound = true
+		}
+		if order.Price() < tillLevel && firstIdxFound {
+			return BaseAmount(n.BuyOrders[firstIdx:idx], n.BuyOrders[0].BasePrecision())
+		}
+	}
+	if firstIdxFound {
+		return BaseAmount(n.BuyOrders[firstIdx:], n.BuyOrders[0].BasePrecision())
+	}
+	return 0
+}
+
+const benchNetSize = 5000
+
+func benchOrders(n int) []*NetOrder {
+	r := rand.New(rand.NewSource(1))
+	orders := make([]*NetOrder, 0, n)
+	for i := 0; i < n; i++ {
+		orders = append(orders, newTestOrder(fmt.Sprintf("%d", i), Buy, Round(40+r.Float64()*20, 2), 1, 0))
+	}
+	return orders
+}
+
+func BenchmarkSliceNet_InsertRemove(b *testing.B) {
+	orders := benchOrders(benchNetSize)
+	net := &sliceNet{}
+	for _, order := range orders {
+		net.InsertOrder(order)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		order := orders[i%len(orders)]
+		net.RemoveOrder(order.ID())
+		net.InsertOrder(order)
+	}
+}
+
+func BenchmarkClassicNet_InsertRemove(b *testing.B) {
+	orders := benchOrders(benchNetSize)
+	net := NewEmptyClassicNet()
+	for _, order := range orders {
+		net.InsertOrder(order)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		order := orders[i%len(orders)]
+		net.RemoveOrder(order.ID())
+		net.InsertOrder(order)
+	}
+}
+
+func BenchmarkSliceNet_BaseAmountFromTillLevel(b *testing.B) {
+	net := &sliceNet{}
+	for _, order := range benchOrders(benchNetSize) {
+		net.InsertOrder(order)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		net.BaseAmountFromTillLevel(Buy, 55, 45)
+	}
+}
+
+func BenchmarkClassicNet_BaseAmountFromTillLevel(b *testing.B) {
+	net := NewEmptyClassicNet()
+	for _, order := range benchOrders(benchNetSize) {
+		net.InsertOrder(order)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		net.BaseAmountFromTillLevel(Buy, 55, 45)
+	}
+}
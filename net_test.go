@@ -3,6 +3,9 @@ package exchange_models
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"sync"
 	"testing"
 )
 
@@ -83,3 +86,285 @@ func TestNetOrder_BaseAmount(t *testing.T) {
 	fmt.Println(net.BaseAmountFromTillLevel(side, 5, 4))
 
 }
+
+func TestClassicNet_Concurrent(t *testing.T) {
+	net := NewEmptyClassicNet()
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				id := fmt.Sprintf("%d-%d", w, i)
+				side := Buy
+				price := 50 - float64(i%20)*0.1
+				if i%2 == 1 {
+					side = Sell
+					price = 60 + float64(i%20)*0.1
+				}
+				net.InsertOrder(newTestOrder(id, side, price, 1, 0))
+				if i%3 == 0 {
+					assert.True(t, net.RemoveOrder(id))
+				}
+			}
+		}(w)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			// покупки не выше 50, продажи не ниже 60
+			if length, _ := net.Spread(); length != 0 {
+				assert.GreaterOrEqual(t, math.Abs(length), 10.0-1e-9)
+			}
+			snapshot := net.Snapshot()
+			assert.Equal(t, float64(len(snapshot.Orders(Buy))), snapshot.BaseAmount(Buy))
+			net.WeightedAveragePrice(Sell)
+			net.BaseAmountFromTillLevel(Buy, 50, 48)
+			for _, order := range net.Orders(Sell) {
+				assert.Equal(t, Sell, order.Side())
+			}
+		}
+	}()
+	wg.Wait()
+
+	buyOrders := net.Orders(Buy)
+	sellOrders := net.Orders(Sell)
+	assert.Len(t, buyOrders, 4*66)
+	assert.Len(t, sellOrders, 4*67)
+	for i := 1; i < len(buyOrders); i++ {
+		assert.GreaterOrEqual(t, buyOrders[i-1].Price(), buyOrders[i].Price())
+	}
+	for i := 1; i < len(sellOrders); i++ {
+		assert.LessOrEqual(t, sellOrders[i-1].Price(), sellOrders[i].Price())
+	}
+}
+
+// поллер исполняет ордер через UpdateOrder, пока читатели держат ордера, полученные из сети
+func TestClassicNet_UpdateWhileReading(t *testing.T) {
+	net := newTestNet(newTestOrder("1", Buy, 54, 100, 0), newTestOrder("2", Sell, 56, 1, 0))
+	held, ok := net.Best(Buy)
+	assert.True(t, ok)
+	events, unsubscribe := net.SubscribeChan(16)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer unsubscribe()
+		for i := 0; i < 200; i++ {
+			_, err := net.UpdateOrder("1", func(order *NetOrder) {
+				assert.NoError(t, order.AddFilledAmount(0.1))
+			})
+			assert.NoError(t, err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			best, ok := net.Best(Buy)
+			assert.True(t, ok)
+			assert.LessOrEqual(t, best.FilledAmount(), 20.0+1e-9)
+			order, ok := net.OrderByID("1")
+			assert.True(t, ok)
+			order.UnfilledAmount()
+			for _, order := range net.OrdersInRange(Buy, 50, 60) {
+				order.FilledAmount()
+			}
+			assert.Zero(t, held.FilledAmount())
+		}
+	}()
+	prev := 0.0
+	for event := range events {
+		if event.Type == OrderFilled {
+			assert.Greater(t, event.Order.FilledAmount(), prev)
+			prev = event.Order.FilledAmount()
+		}
+	}
+	wg.Wait()
+
+	// отданные ордера - копии, их изменение сеть не трогает
+	best, _ := net.Best(Buy)
+	assert.InDelta(t, 20.0, best.FilledAmount(), 1e-9)
+	best.SetPrice(1)
+	for _, order := range net.Orders(Buy) {
+		order.SetBaseAmount(1)
+	}
+	order, _ := net.OrderByID("1")
+	assert.Equal(t, 54.0, order.Price())
+	assert.Equal(t, 100.0, net.BaseAmount(Buy))
+}
+
+func TestClassicNet_Snapshot(t *testing.T) {
+	net := newTestNet(newTestOrder("1", Buy, 54, 1, 0), newTestOrder("2", Sell, 56, 2, 0))
+	snapshot := net.Snapshot()
+	net.RemoveOrder("1")
+	net.Orders(Sell)[0].SetStatus(Cancelled)
+
+	assert.Len(t, snapshot.Orders(Buy), 1)
+	assert.Equal(t, New, snapshot.Orders(Sell)[0].Status())
+	assert.Equal(t, 2.0, snapshot.BaseAmount(Sell))
+	assert.Len(t, net.Orders(Buy), 0)
+}
+
+func TestClassicNet_MatchesSliceNet(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	net := NewEmptyClassicNet()
+	reference := &sliceNet{}
+	ids := make([]string, 0)
+	for i := 0; i < 2000; i++ {
+		if len(ids) > 0 && r.Intn(3) == 0 {
+			idx := r.Intn(len(ids))
+			assert.Equal(t, reference.RemoveOrder(ids[idx]), net.RemoveOrder(ids[idx]))
+			ids = append(ids[:idx], ids[idx+1:]...)
+			continue
+		}
+		id := fmt.Sprintf("%d", i)
+		order := newTestOrder(id, Buy, Round(50+float64(r.Intn(50))*0.1, 2), Round(r.Float64()*3, 3), 0)
+		net.InsertOrder(order)
+		reference.InsertOrder(order)
+		ids = append(ids, id)
+
+		from, till := Round(50+r.Float64()*5, 2), Round(50+r.Float64()*5, 2)
+		assert.Equal(t, reference.BaseAmountFromTillLevel(Buy, from, till), net.BaseAmountFromTillLevel(Buy, from, till))
+		assert.Equal(t, Round(QuoteAmount(net.OrdersInRange(Buy, from, till)), 5), net.QuoteAmountFromTillLevel(Buy, from, till))
+	}
+	assert.Equal(t, reference.BuyOrders, net.Orders(Buy))
+	assert.Equal(t, reference.BuyOrders, net.BuyOrders())
+	assert.Empty(t, net.SellOrders())
+	assert.False(t, net.RemoveOrder("missing"))
+}
+
+func TestClassicNet_Lookups(t *testing.T) {
+	net := newTestNet(
+		newTestOrder("1", Buy, 54, 1, 0),
+		newTestOrder("2", Buy, 53, 2, 0),
+		newTestOrder("3", Buy, 53, 0.5, 0),
+		newTestOrder("4", Buy, 52, 3, 0),
+		newTestOrder("5", Sell, 56, 1, 0),
+		newTestOrder("6", Sell, 57, 2, 0),
+	)
+
+	order, ok := net.OrderByID("3")
+	assert.True(t, ok)
+	assert.Equal(t, 0.5, order.BaseAmount())
+	_, ok = net.OrderByID("7")
+	assert.False(t, ok)
+
+	best, ok := net.Best(Buy)
+	assert.True(t, ok)
+	assert.Equal(t, "1", best.ID())
+	best, ok = net.Best(Sell)
+	assert.True(t, ok)
+	assert.Equal(t, "5", best.ID())
+	_, ok = NewEmptyClassicNet().Best(Sell)
+	assert.False(t, ok)
+
+	assert.Equal(t, 4, net.Len(Buy))
+	assert.Equal(t, 2, net.Len(Sell))
+
+	ids := make([]string, 0)
+	for _, order := range net.OrdersInRange(Buy, 52, 53) {
+		ids = append(ids, order.ID())
+	}
+	assert.Equal(t, []string{"2", "3", "4"}, ids)
+	assert.Len(t, net.OrdersInRange(Sell, 57, 56), 2)
+	assert.Len(t, net.OrdersInRange(Sell, 56.5, 56.9), 0)
+
+	assert.Equal(t, 2.5, net.BaseAmountFromTillLevel(Buy, 53, 53))
+	assert.Equal(t, 53*2.5+52*3, net.QuoteAmountFromTillLevel(Buy, 52, 53))
+	assert.Equal(t, 56.0+57*2, net.QuoteAmountFromTillLevel(Sell, 50, 60))
+
+	ids = ids[:0]
+	for idx, order := range net.All(Sell) {
+		assert.Equal(t, len(ids), idx)
+		ids = append(ids, order.ID())
+		net.RemoveOrder(order.ID())
+	}
+	assert.Equal(t, []string{"5", "6"}, ids)
+	assert.Equal(t, 0, net.Len(Sell))
+	for range net.All(Buy) {
+		break
+	}
+}
+
+func TestClassicNet_UpdateOrder(t *testing.T) {
+	net := newTestNet(
+		newTestOrder("1", Sell, 56, 1, 0),
+		newTestOrder("2", Sell, 56, 2, 0),
+		newTestOrder("3", Sell, 57, 3, 0),
+	)
+
+	event, err := net.UpdateOrder("1", func(order *NetOrder) {
+		assert.NoError(t, order.AddFilledAmount(0.4))
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, OrderFilled, event.Type)
+	assert.False(t, event.Removed)
+	assert.Equal(t, 0.0, event.OldFilledAmount)
+	assert.Equal(t, "1", net.Orders(Sell)[0].ID())
+
+	event, err = net.UpdateOrder("1", func(order *NetOrder) {
+		order.SetBaseAmount(1.5)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, OrderUpdated, event.Type)
+	assert.Equal(t, "1", net.Orders(Sell)[0].ID())
+	assert.Equal(t, 6.5, net.BaseAmount(Sell))
+
+	event, err = net.UpdateOrder("1", func(order *NetOrder) {
+		order.SetPrice(57)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, OrderRepriced, event.Type)
+	assert.Equal(t, 56.0, event.OldPrice)
+	ids := make([]string, 0)
+	for _, order := range net.Orders(Sell) {
+		ids = append(ids, order.ID())
+	}
+	assert.Equal(t, []string{"2", "3", "1"}, ids)
+	assert.Equal(t, 2.0, net.BaseAmountFromTillLevel(Sell, 56, 56))
+
+	event, err = net.UpdateOrder("2", func(order *NetOrder) {
+		assert.NoError(t, order.AddFilledAmount(2))
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, OrderFilled, event.Type)
+	assert.True(t, event.Removed)
+	assert.Equal(t, Filled, event.Order.Status())
+	assert.False(t, event.Order.DeathDate().IsZero())
+	_, ok := net.OrderByID("2")
+	assert.False(t, ok)
+
+	event, err = net.UpdateOrder("3", func(order *NetOrder) {
+		order.SetStatus(Cancelled)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, OrderRemoved, event.Type)
+	assert.Equal(t, 1, net.Len(Sell))
+
+	_, err = net.UpdateOrder("3", func(order *NetOrder) {})
+	assert.Error(t, err)
+}
+
+func TestClassicNet_MutatedOutsideNet(t *testing.T) {
+	first := newTestOrder("1", Sell, 56, 1, 0)
+	net := newTestNet(first, newTestOrder("2", Sell, 57, 2, 0), newTestOrder("3", Sell, 58, 3, 0))
+
+	// изменения в обход UpdateOrder не ломают дерево: ордер стоит на старой цене со старым объемом
+	first.SetPrice(59).SetBaseAmount(10)
+	assert.Equal(t, 6.0, net.BaseAmount(Sell))
+	assert.Equal(t, 1.0, net.BaseAmountFromTillLevel(Sell, 56, 56))
+	assert.True(t, net.RemoveOrder("2"))
+	assert.Equal(t, 4.0, net.BaseAmount(Sell))
+
+	// UpdateOrder переставляет ордер по текущим значениям
+	event, err := net.UpdateOrder("1", func(order *NetOrder) {})
+	assert.NoError(t, err)
+	assert.Equal(t, OrderRepriced, event.Type)
+	assert.Equal(t, 56.0, event.OldPrice)
+	assert.Equal(t, 1.0, event.OldBaseAmount)
+	assert.Equal(t, 13.0, net.BaseAmount(Sell))
+	assert.Equal(t, "3", net.Orders(Sell)[0].ID())
+	assert.True(t, net.RemoveOrder("1"))
+	assert.Equal(t, 1, net.Len(Sell))
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"time"
 )
 
@@ -57,6 +58,8 @@ type Level struct {
 	Price      float64
 	BuyAmount  float64
 	SellAmount float64
+	ID         string    // id сделки, у уровней стакана пустой
+	Time       time.Time // время сделки
 }
 
 type NetOrderConfig struct {
@@ -138,10 +141,24 @@ func (o *NetOrder) Price() float64 {
 	return o.price
 }
 
+// SetPrice у ордера внутри ClassicNet цену нужно менять через UpdateOrder,
+// иначе сеть продолжит считать его стоящим на старой цене
+func (o *NetOrder) SetPrice(price float64) *NetOrder {
+	o.price = price
+	return o
+}
+
 func (o *NetOrder) BaseAmount() float64 {
 	return o.baseAmount
 }
 
+// SetBaseAmount у ордера внутри ClassicNet объем нужно менять через UpdateOrder,
+// иначе суммы сети останутся прежними
+func (o *NetOrder) SetBaseAmount(baseAmount float64) *NetOrder {
+	o.baseAmount = baseAmount
+	return o
+}
+
 func (o *NetOrder) QuoteAmount() float64 {
 	return o.baseAmount * o.price
 }
@@ -161,7 +178,11 @@ func (o *NetOrder) UnfilledAmount() float64 {
 func (o *NetOrder) AddFilledAmount(amount float64) error {
 	res := o.filledAmount + amount
 	if res > o.baseAmount {
-		return errors.New("new base amount is larger than initial")
+		// ошибка округления при доисполнении до полного объема
+		if res-o.baseAmount > 1e-9*math.Max(1, o.baseAmount) {
+			return errors.New("new base amount is larger than initial")
+		}
+		res = o.baseAmount
 	}
 	o.filledAmount = res
 	return nil
@@ -202,6 +223,12 @@ func (o *NetOrder) PricePrecision() int {
 	return o.pricePrec
 }
 
+// clone копия ордера, которую можно менять и отдавать наружу отдельно от оригинала
+func (o *NetOrder) clone() *NetOrder {
+	c := *o
+	return &c
+}
+
 func (o *NetOrder) Print() {
 	fmt.Printf("%s, %s, %s, %s, %s, price: %f, base amount: %f, filled: %f, previous id: %s, created: %s, ended: %s, base prec: %d, price prec: %d\n",
 		o.exchangeName,
@@ -220,28 +247,32 @@ func (o *NetOrder) Print() {
 }
 
 func (o *NetOrder) Marshal() ([]byte, error) {
-	orderBytes, err := json.Marshal(NetOrderConfig{
-		ExName:       o.exchangeName,
-		Symbol:       o.symbol,
-		Id:           o.id,
-		Side:         o.side,
-		OrderType:    o.orderType,
-		Status:       o.status,
-		Price:        o.price,
-		BaseAmount:   o.baseAmount,
-		FilledAmount: o.filledAmount,
-		PreviousId:   o.previousId,
-		CreationDate: o.creationDate,
-		DeathDate:    o.deathDate,
-		BasePrec:     o.basePrec,
-		PricePrec:    o.pricePrec,
-	})
+	orderBytes, err := json.Marshal(orderConfig(o))
 	if err != nil {
 		return nil, err
 	}
 	return orderBytes, nil
 }
 
+func orderConfig(order *NetOrder) *NetOrderConfig {
+	return &NetOrderConfig{
+		ExName:       order.ExchangeName(),
+		Symbol:       order.Symbol(),
+		Id:           order.ID(),
+		Side:         order.Side(),
+		OrderType:    order.Type(),
+		Status:       order.Status(),
+		Price:        order.Price(),
+		BaseAmount:   order.BaseAmount(),
+		FilledAmount: order.FilledAmount(),
+		PreviousId:   order.PreviousId(),
+		CreationDate: order.CreationDate(),
+		DeathDate:    order.DeathDate(),
+		BasePrec:     order.BasePrecision(),
+		PricePrec:    order.PricePrecision(),
+	}
+}
+
 func UnmarshalNetOrder(orderBytes []byte) (*NetOrder, error) {
 	var netOrderConfig NetOrderConfig
 	err := json.Unmarshal(orderBytes, &netOrderConfig)
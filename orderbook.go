@@ -0,0 +1,277 @@
+package exchange_models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LocalOrderBook локальная копия стакана: заполняется через FullOrderBook,
+// дальше живет на обновлениях из StreamingConnector и пересинхронизируется при пропуске sequence
+type LocalOrderBook struct {
+	connector      Connector
+	base           string
+	quote          string
+	basePrecision  int
+	pricePrecision int
+
+	applyMu  sync.Mutex // Apply и Sync идут по одному, чтобы обновления не терялись во время загрузки
+	mu       sync.RWMutex
+	bids     []*Level // по убыванию цены, как BuyOrders в ClassicNet
+	asks     []*Level // по возрастанию цены, как SellOrders
+	sequence int64    // номер последнего обновления, стакан после Sync не старше его. 0 - обновлений еще не было
+	resyncs  int
+}
+
+func NewLocalOrderBook(connector Connector, base, quote string, basePrecision, pricePrecision int) *LocalOrderBook {
+	return &LocalOrderBook{
+		connector:      connector,
+		base:           base,
+		quote:          quote,
+		basePrecision:  basePrecision,
+		pricePrecision: pricePrecision,
+		bids:           make([]*Level, 0),
+		asks:           make([]*Level, 0),
+	}
+}
+
+// Sync заново загружает обе стороны стакана через FullOrderBook. Sequence сохраняется:
+// загруженный стакан не старше последнего обновления, и обновления с номером не больше него пропускаются
+func (b *LocalOrderBook) Sync() error {
+	b.applyMu.Lock()
+	defer b.applyMu.Unlock()
+	return b.sync()
+}
+
+// sync загружает стакан, пока читатели видят прежнюю копию. Вызывается под applyMu
+func (b *LocalOrderBook) sync() error {
+	buyOrders, err := b.connector.FullOrderBook(b.base, b.quote, Buy, b.basePrecision, b.pricePrecision)
+	if err != nil {
+		return err
+	}
+	sellOrders, err := b.connector.FullOrderBook(b.base, b.quote, Sell, b.basePrecision, b.pricePrecision)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bids = b.bids[:0]
+	b.asks = b.asks[:0]
+	for _, order := range buyOrders {
+		b.addAmount(Buy, order.Price(), order.UnfilledAmount())
+	}
+	for _, order := range sellOrders {
+		b.addAmount(Sell, order.Price(), order.UnfilledAmount())
+	}
+	return nil
+}
+
+// Apply применяет снимок или изменение. Устаревшие обновления пропускаются,
+// при разрыве в sequence стакан загружается заново через Sync и обновление применяется поверх него.
+// Конкурентные Apply ждут окончания загрузки
+func (b *LocalOrderBook) Apply(update *BookUpdate) error {
+	if update.Symbol != "" && update.Symbol != symbol(b.base, b.quote) {
+		return fmt.Errorf("update for %s applied to %s order book", update.Symbol, symbol(b.base, b.quote))
+	}
+	b.applyMu.Lock()
+	defer b.applyMu.Unlock()
+	b.mu.RLock()
+	sequence := b.sequence
+	b.mu.RUnlock()
+	if !update.Snapshot && update.Sequence <= sequence {
+		return nil
+	}
+	if !update.Snapshot && sequence != 0 && update.Sequence != sequence+1 {
+		if err := b.sync(); err != nil {
+			return err
+		}
+		b.mu.Lock()
+		b.resyncs++
+		b.mu.Unlock()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if update.Snapshot {
+		b.bids = b.bids[:0]
+		b.asks = b.asks[:0]
+	}
+	b.applyLevels(update)
+	b.sequence = update.Sequence
+	return nil
+}
+
+// Run применяет обновления из канала, пока он не закроется
+func (b *LocalOrderBook) Run(updates <-chan *BookUpdate) error {
+	for update := range updates {
+		if err := b.Apply(update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *LocalOrderBook) applyLevels(update *BookUpdate) {
+	for _, level := range update.Bids {
+		b.setAmount(Buy, level.Price, level.BuyAmount)
+	}
+	for _, level := range update.Asks {
+		b.setAmount(Sell, level.Price, level.SellAmount)
+	}
+}
+
+func (b *LocalOrderBook) side(side Side) *[]*Level {
+	if side == Buy {
+		return &b.bids
+	}
+	return &b.asks
+}
+
+// search возвращает индекс уровня с ценой price или место для его вставки
+func (b *LocalOrderBook) search(side Side, price float64) (int, bool) {
+	levels := *b.side(side)
+	idx := sort.Search(len(levels), func(i int) bool {
+		if side == Buy {
+			return levels[i].Price <= price
+		}
+		return levels[i].Price >= price
+	})
+	return idx, idx < len(levels) && Equals(levels[idx].Price, price, b.priceEps())
+}
+
+func (b *LocalOrderBook) setAmount(side Side, price, amount float64) {
+	price = Round(price, b.pricePrecision)
+	amount = Round(amount, b.basePrecision)
+	levels := b.side(side)
+	idx, found := b.search(side, price)
+	switch {
+	case found && amount <= 0:
+		*levels = append((*levels)[:idx], (*levels)[idx+1:]...)
+	case found:
+		setLevelAmount((*levels)[idx], side, amount)
+	case amount > 0:
+		level := &Level{Price: price}
+		setLevelAmount(level, side, amount)
+		*levels = append(*levels, nil)
+		copy((*levels)[idx+1:], (*levels)[idx:])
+		(*levels)[idx] = level
+	}
+}
+
+func (b *LocalOrderBook) addAmount(side Side, price, amount float64) {
+	price = Round(price, b.pricePrecision)
+	idx, found := b.search(side, price)
+	if found {
+		amount += levelAmount((*b.side(side))[idx], side)
+	}
+	b.setAmount(side, price, amount)
+}
+
+func (b *LocalOrderBook) priceEps() float64 {
+	return precisionEps(b.pricePrecision)
+}
+
+func (b *LocalOrderBook) BestBid() (price, amount float64, ok bool) {
+	return b.best(Buy)
+}
+
+func (b *LocalOrderBook) BestAsk() (price, amount float64, ok bool) {
+	return b.best(Sell)
+}
+
+func (b *LocalOrderBook) best(side Side) (price, amount float64, ok bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	levels := *b.side(side)
+	if len(levels) == 0 {
+		return 0, 0, false
+	}
+	return levels[0].Price, levelAmount(levels[0], side), true
+}
+
+// DepthAt объем на уровне price
+func (b *LocalOrderBook) DepthAt(side Side, price float64) float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	idx, found := b.search(side, Round(price, b.pricePrecision))
+	if !found {
+		return 0
+	}
+	return levelAmount((*b.side(side))[idx], side)
+}
+
+// CumulativeDepth суммарный объем от лучшей цены до tillPrice включительно
+func (b *LocalOrderBook) CumulativeDepth(side Side, tillPrice float64) float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	tillPrice = Round(tillPrice, b.pricePrecision)
+	sum := 0.0
+	for _, level := range *b.side(side) {
+		if side == Buy && level.Price < tillPrice || side == Sell && level.Price > tillPrice {
+			break
+		}
+		sum = Round(sum+levelAmount(level, side), b.basePrecision)
+	}
+	return sum
+}
+
+// Levels копия уровней стороны, начиная с лучшей цены
+func (b *LocalOrderBook) Levels(side Side) []*Level {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	levels := *b.side(side)
+	res := make([]*Level, 0, len(levels))
+	for _, level := range levels {
+		l := *level
+		res = append(res, &l)
+	}
+	return res
+}
+
+// Snapshot стакан в виде OrderBookSnapshot, количество заявок на уровнях локально неизвестно
+func (b *LocalOrderBook) Snapshot() *OrderBookSnapshot {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	now := time.Now().UTC()
+	snapshot := &OrderBookSnapshot{
+		Symbol: symbol(b.base, b.quote),
+		Bids:   make([]*BookLevel, 0, len(b.bids)),
+		Asks:   make([]*BookLevel, 0, len(b.asks)),
+		Time:   now,
+	}
+	for _, level := range b.bids {
+		snapshot.Bids = append(snapshot.Bids, &BookLevel{Side: Buy, Price: level.Price, Amount: level.BuyAmount, Time: now})
+	}
+	for _, level := range b.asks {
+		snapshot.Asks = append(snapshot.Asks, &BookLevel{Side: Sell, Price: level.Price, Amount: level.SellAmount, Time: now})
+	}
+	return snapshot
+}
+
+func (b *LocalOrderBook) Sequence() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.sequence
+}
+
+// Resyncs сколько раз стакан перезагружался из-за пропуска обновлений
+func (b *LocalOrderBook) Resyncs() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.resyncs
+}
+
+func levelAmount(level *Level, side Side) float64 {
+	if side == Buy {
+		return level.BuyAmount
+	}
+	return level.SellAmount
+}
+
+func setLevelAmount(level *Level, side Side, amount float64) {
+	if side == Buy {
+		level.BuyAmount = amount
+		return
+	}
+	level.SellAmount = amount
+}
@@ -0,0 +1,141 @@
+package exchange_models
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLocalOrderBook(t *testing.T) (*LocalOrderBook, *mockConnector) {
+	c := newMockConnector()
+	c.buyBook = []*NetOrder{
+		mockBookOrder(Buy, 54.90, 1),
+		mockBookOrder(Buy, 54.90, 0.5),
+		mockBookOrder(Buy, 54.50, 2),
+	}
+	c.sellBook = []*NetOrder{
+		mockBookOrder(Sell, 55.30, 3),
+		mockBookOrder(Sell, 55.10, 1.2),
+	}
+	book := NewLocalOrderBook(c, "SDFA", "USDT", 3, 2)
+	assert.NoError(t, book.Sync())
+	return book, c
+}
+
+func TestLocalOrderBook_Sync(t *testing.T) {
+	book, _ := newTestLocalOrderBook(t)
+
+	price, amount, ok := book.BestBid()
+	assert.True(t, ok)
+	assert.Equal(t, 54.90, price)
+	assert.Equal(t, 1.5, amount)
+
+	price, amount, ok = book.BestAsk()
+	assert.True(t, ok)
+	assert.Equal(t, 55.10, price)
+	assert.Equal(t, 1.2, amount)
+
+	assert.Equal(t, 2.0, book.DepthAt(Buy, 54.5))
+	assert.Equal(t, 0.0, book.DepthAt(Buy, 54.6))
+	assert.Equal(t, 3.5, book.CumulativeDepth(Buy, 54.5))
+	assert.Equal(t, 1.5, book.CumulativeDepth(Buy, 54.6))
+	assert.Equal(t, 4.2, book.CumulativeDepth(Sell, 60))
+
+	asks := book.Levels(Sell)
+	assert.Len(t, asks, 2)
+	assert.Equal(t, 55.10, asks[0].Price)
+	assert.Equal(t, 55.30, asks[1].Price)
+}
+
+func TestLocalOrderBook_Apply(t *testing.T) {
+	book, c := newTestLocalOrderBook(t)
+
+	assert.NoError(t, book.Apply(&BookUpdate{
+		Symbol:   "SDFA_USDT",
+		Sequence: 7,
+		Bids:     []*Level{{Price: 54.90, BuyAmount: 0}, {Price: 54.70, BuyAmount: 4}},
+		Asks:     []*Level{{Price: 55.00, SellAmount: 0.1}},
+	}))
+	price, _, _ := book.BestBid()
+	assert.Equal(t, 54.70, price)
+	price, amount, _ := book.BestAsk()
+	assert.Equal(t, 55.00, price)
+	assert.Equal(t, 0.1, amount)
+	assert.Equal(t, int64(7), book.Sequence())
+
+	// устаревшее обновление пропускается
+	assert.NoError(t, book.Apply(&BookUpdate{Sequence: 6, Asks: []*Level{{Price: 55.00, SellAmount: 0}}}))
+	assert.Equal(t, 0.1, book.DepthAt(Sell, 55.00))
+
+	// пропуск sequence перезагружает стакан через FullOrderBook
+	calls := c.bookCalls
+	assert.NoError(t, book.Apply(&BookUpdate{Sequence: 9, Asks: []*Level{{Price: 56, SellAmount: 1}}}))
+	assert.Equal(t, calls+2, c.bookCalls)
+	assert.Equal(t, 1, book.Resyncs())
+	assert.Equal(t, int64(9), book.Sequence())
+	assert.Equal(t, 0.0, book.DepthAt(Sell, 55.00))
+	assert.Equal(t, 1.5, book.DepthAt(Buy, 54.90))
+	// обновление, вызвавшее загрузку, применяется поверх нее
+	assert.Equal(t, 1.0, book.DepthAt(Sell, 56))
+	// номер загруженного стакана сохраняется, старые обновления поверх него не применяются
+	assert.NoError(t, book.Apply(&BookUpdate{Sequence: 8, Asks: []*Level{{Price: 56, SellAmount: 0}}}))
+	assert.Equal(t, 1.0, book.DepthAt(Sell, 56))
+	assert.NoError(t, book.Sync())
+	assert.Equal(t, int64(9), book.Sequence())
+	assert.NoError(t, book.Apply(&BookUpdate{Sequence: 9, Asks: []*Level{{Price: 56, SellAmount: 1}}}))
+	assert.Equal(t, 0.0, book.DepthAt(Sell, 56))
+	assert.Equal(t, 1, book.Resyncs())
+
+	assert.NoError(t, book.Apply(&BookUpdate{Sequence: 20, Snapshot: true, Bids: []*Level{{Price: 50, BuyAmount: 1}}}))
+	assert.Len(t, book.Levels(Buy), 1)
+	assert.Len(t, book.Levels(Sell), 0)
+	_, _, ok := book.BestAsk()
+	assert.False(t, ok)
+
+	assert.Error(t, book.Apply(&BookUpdate{Symbol: "BTC_USDT", Sequence: 21}))
+}
+
+// blockingBookConnector держит FullOrderBook, пока не закрыт release
+type blockingBookConnector struct {
+	*mockConnector
+	entered chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (c *blockingBookConnector) FullOrderBook(base, quote string, side Side, basePrecision, pricePrecision int) ([]*NetOrder, error) {
+	c.once.Do(func() {
+		close(c.entered)
+		<-c.release
+	})
+	return c.mockConnector.FullOrderBook(base, quote, side, basePrecision, pricePrecision)
+}
+
+func TestLocalOrderBook_ApplyDuringResync(t *testing.T) {
+	c := &blockingBookConnector{mockConnector: newMockConnector(), entered: make(chan struct{}), release: make(chan struct{})}
+	c.sellBook = []*NetOrder{mockBookOrder(Sell, 55.10, 1.2)}
+	book := NewLocalOrderBook(c, "SDFA", "USDT", 3, 2)
+	assert.NoError(t, book.Apply(&BookUpdate{Sequence: 1, Snapshot: true}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, book.Apply(&BookUpdate{Sequence: 5, Asks: []*Level{{Price: 56, SellAmount: 1}}}))
+	}()
+	<-c.entered
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, book.Apply(&BookUpdate{Sequence: 6, Asks: []*Level{{Price: 57, SellAmount: 2}}}))
+	}()
+	close(c.release)
+	wg.Wait()
+
+	assert.Equal(t, int64(6), book.Sequence())
+	assert.Equal(t, 1, book.Resyncs())
+	assert.Equal(t, 1.2, book.DepthAt(Sell, 55.10))
+	assert.Equal(t, 1.0, book.DepthAt(Sell, 56))
+	assert.Equal(t, 2.0, book.DepthAt(Sell, 57))
+}
@@ -0,0 +1,220 @@
+package exchange_models
+
+// orderTree декартово дерево ордеров одной стороны, упорядоченных как в стакане:
+// сначала лучшая цена, при равной цене - более ранний ордер.
+// В узлах хранятся суммы base и quote по поддереву, поэтому объем на любом диапазоне цен
+// считается за O(log n). Цена и объем ордера запоминаются в узле при вставке: если ордер
+// поменяли в обход ClassicNet.UpdateOrder, дерево остается целым до следующей перестановки узла
+type orderTree struct {
+	side Side
+	root *treeNode
+	seq  uint64
+}
+
+type treeNode struct {
+	order    *NetOrder
+	price    float64 // ключ узла, цена ордера на момент вставки
+	ownBase  float64
+	ownQuote float64
+	seq      uint64
+	priority uint64
+	left     *treeNode
+	right    *treeNode
+	size     int
+	base     float64
+	quote    float64
+}
+
+func newOrderTree(side Side) *orderTree {
+	return &orderTree{side: side}
+}
+
+func (t *orderTree) len() int {
+	return t.root.getSize()
+}
+
+// before ордер с ценой price и номером seq стоит раньше узла nd
+func (t *orderTree) before(price float64, seq uint64, nd *treeNode) bool {
+	if price != nd.price {
+		return t.better(price, nd.price)
+	}
+	return seq < nd.seq
+}
+
+func (t *orderTree) better(p1, p2 float64) bool {
+	if t.side == Buy {
+		return p1 > p2
+	}
+	return p1 < p2
+}
+
+func (t *orderTree) insert(order *NetOrder) *treeNode {
+	t.seq++
+	nd := &treeNode{
+		order:    order,
+		seq:      t.seq,
+		priority: mix64(t.seq),
+	}
+	t.reinsert(nd)
+	return nd
+}
+
+// reinsert ставит узел на его место в очереди, цена и объемы берутся из ордера заново
+func (t *orderTree) reinsert(nd *treeNode) {
+	nd.left, nd.right = nil, nil
+	nd.price, nd.ownBase, nd.ownQuote = nd.order.Price(), nd.order.BaseAmount(), nd.order.QuoteAmount()
+	nd.update()
+	left, right := t.split(t.root, nd.price, nd.seq)
+	t.root = merge(merge(left, nd), right)
+}
+
+func (t *orderTree) remove(nd *treeNode) {
+	left, rest := t.split(t.root, nd.price, nd.seq-1)
+	_, right := t.split(rest, nd.price, nd.seq)
+	t.root = merge(left, right)
+}
+
+// split делит дерево на узлы не позже (price, seq) и все остальные
+func (t *orderTree) split(nd *treeNode, price float64, seq uint64) (*treeNode, *treeNode) {
+	if nd == nil {
+		return nil, nil
+	}
+	if t.before(price, seq, nd) {
+		left, right := t.split(nd.left, price, seq)
+		nd.left = right
+		nd.update()
+		return left, nd
+	}
+	left, right := t.split(nd.right, price, seq)
+	nd.right = left
+	nd.update()
+	return nd, right
+}
+
+func merge(left, right *treeNode) *treeNode {
+	if left == nil {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+	if left.priority > right.priority {
+		left.right = merge(left.right, right)
+		left.update()
+		return left
+	}
+	right.left = merge(left, right.left)
+	right.update()
+	return right
+}
+
+func (nd *treeNode) update() {
+	nd.size = 1 + nd.left.getSize() + nd.right.getSize()
+	nd.base = nd.ownBase + nd.left.getBase() + nd.right.getBase()
+	nd.quote = nd.ownQuote + nd.left.getQuote() + nd.right.getQuote()
+}
+
+func (nd *treeNode) getSize() int {
+	if nd == nil {
+		return 0
+	}
+	return nd.size
+}
+
+func (nd *treeNode) getBase() float64 {
+	if nd == nil {
+		return 0
+	}
+	return nd.base
+}
+
+func (nd *treeNode) getQuote() float64 {
+	if nd == nil {
+		return 0
+	}
+	return nd.quote
+}
+
+func (t *orderTree) first() *NetOrder {
+	nd := t.root
+	if nd == nil {
+		return nil
+	}
+	for nd.left != nil {
+		nd = nd.left
+	}
+	return nd.order
+}
+
+// prefix суммы base и quote и количество ордеров с ценой лучше price,
+// а при inclusive и с ценой равной price
+func (t *orderTree) prefix(price float64, inclusive bool) (base, quote float64, count int) {
+	nd := t.root
+	for nd != nil {
+		p := nd.price
+		if t.better(p, price) || inclusive && p == price {
+			base += nd.ownBase + nd.left.getBase()
+			quote += nd.ownQuote + nd.left.getQuote()
+			count += 1 + nd.left.getSize()
+			nd = nd.right
+		} else {
+			nd = nd.left
+		}
+	}
+	return
+}
+
+// rangeSum суммы по ордерам с ценами от from до till включительно, from ближе к лучшей цене
+func (t *orderTree) rangeSum(from, till float64) (base, quote float64, count int) {
+	tillBase, tillQuote, tillCount := t.prefix(till, true)
+	fromBase, fromQuote, fromCount := t.prefix(from, false)
+	return tillBase - fromBase, tillQuote - fromQuote, tillCount - fromCount
+}
+
+// walk обходит ордера от лучшей цены, пока f возвращает true
+func (t *orderTree) walk(f func(order *NetOrder) bool) {
+	walkNode(t.root, f)
+}
+
+func walkNode(nd *treeNode, f func(order *NetOrder) bool) bool {
+	if nd == nil {
+		return true
+	}
+	return walkNode(nd.left, f) && f(nd.order) && walkNode(nd.right, f)
+}
+
+// walkRange обходит ордера с ценами от from до till включительно, from ближе к лучшей цене
+func (t *orderTree) walkRange(from, till float64, f func(order *NetOrder) bool) {
+	t.walkRangeNode(t.root, from, till, f)
+}
+
+func (t *orderTree) walkRangeNode(nd *treeNode, from, till float64, f func(order *NetOrder) bool) bool {
+	if nd == nil {
+		return true
+	}
+	p := nd.price
+	if t.better(p, from) {
+		return t.walkRangeNode(nd.right, from, till, f)
+	}
+	if t.better(till, p) {
+		return t.walkRangeNode(nd.left, from, till, f)
+	}
+	return t.walkRangeNode(nd.left, from, till, f) && f(nd.order) && t.walkRangeNode(nd.right, from, till, f)
+}
+
+func (t *orderTree) orders() []*NetOrder {
+	res := make([]*NetOrder, 0, t.len())
+	t.walk(func(order *NetOrder) bool {
+		res = append(res, order)
+		return true
+	})
+	return res
+}
+
+// mix64 splitmix64, дает приоритеты узлов без общего генератора случайных чисел
+func mix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
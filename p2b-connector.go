@@ -1,9 +1,11 @@
 package exchange_models
 
 import (
+	"errors"
 	"fmt"
 	"github.com/sutapurachina/go-p2pb2b"
 	"strconv"
+	"time"
 )
 
 const (
@@ -11,6 +13,11 @@ const (
 	P2BSell = "sell"
 )
 
+// p2bOrderLookupWindow за сколько последних часов OrderByID ищет сделки закрытого ордера
+const p2bOrderLookupWindow = 24 * time.Hour
+
+var _ OrderLookup = (*P2BConnector)(nil)
+
 type P2BConnector struct {
 	Connector
 	Client p2pb2b.Client
@@ -104,7 +111,7 @@ func (c *P2BConnector) OpenOrders(base, quote string, basePrecision, pricePrecis
 			return nil, err
 		}
 		status := New
-		if left > 0 {
+		if left < amount {
 			status = PartiallyFilled
 		}
 		orderConfig := &NetOrderConfig{
@@ -148,7 +155,7 @@ func (c *P2BConnector) OrderBook(base, quote string, side Side, basePrecision, p
 		amount := order.Amount
 		left := order.Left
 		status := New
-		if left > 0 {
+		if left < amount {
 			status = PartiallyFilled
 		}
 		orderConfig := &NetOrderConfig{
@@ -192,14 +199,52 @@ func (c *P2BConnector) FullOrderBook(base, quote string, side Side, basePrecisio
 	return res, nil
 }
 
+// Depth берет агрегированный стакан из depth endpoint, без обхода всех заявок.
+// Количество заявок на уровнях endpoint не отдает
+func (c *P2BConnector) Depth(base, quote string, basePrecision, pricePrecision int, limit int64) (*OrderBookSnapshot, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	res, err := c.Client.GetDepthResult(symbol(base, quote), limit)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	return &OrderBookSnapshot{
+		Symbol: symbol(base, quote),
+		Bids:   p2bDepthLevels(Buy, res.Result.Bids, basePrecision, pricePrecision, now, limit),
+		Asks:   p2bDepthLevels(Sell, res.Result.Asks, basePrecision, pricePrecision, now, limit),
+		Time:   now,
+	}, nil
+}
+
+// p2bDepthLevels уровни из пар [цена, объем] depth endpoint
+func p2bDepthLevels(side Side, rows [][]float64, basePrecision, pricePrecision int, at time.Time, limit int64) []*BookLevel {
+	levels := newBookLevels(side, basePrecision, pricePrecision, at)
+	for _, row := range rows {
+		if len(row) < 2 {
+			continue
+		}
+		levels.addLevel(row[0], row[1])
+	}
+	return levels.sorted(limit)
+}
+
+// BestBidBestAsk лучшие цены, у пустой стороны стакана цена 0
 func (c *P2BConnector) BestBidBestAsk(base, quote string) (bestBid, bestAsk float64, err error) {
 	res, err := c.Client.GetDepthResult(symbol(base, quote), 1)
 	if err != nil {
 		return 0, 0, err
 	}
-	bestBid = res.Result.Bids[0][0]
-	bestAsk = res.Result.Asks[0][0]
-	return
+	return p2bTopPrice(res.Result.Bids), p2bTopPrice(res.Result.Asks), nil
+}
+
+// p2bTopPrice цена первого уровня стороны depth или 0, если сторона пуста
+func p2bTopPrice(levels [][]float64) float64 {
+	if len(levels) == 0 || len(levels[0]) == 0 {
+		return 0
+	}
+	return levels[0][0]
 }
 
 func (c *P2BConnector) LastPrice(base, quote string) (lastPrice float64, err error) {
@@ -210,6 +255,32 @@ func (c *P2BConnector) LastPrice(base, quote string) (lastPrice float64, err err
 	return res.Result.Last, nil
 }
 
+// Ticker берет статистику из ticker эндпоинта, при ошибке считает ее по сделкам
+func (c *P2BConnector) Ticker(base, quote string) (*Ticker, error) {
+	res, err := c.Client.GetTicker(symbol(base, quote))
+	if err != nil {
+		ticker, fallbackErr := TickerFromDealHistory(c, base, quote)
+		if fallbackErr != nil {
+			return nil, errors.Join(err, fallbackErr)
+		}
+		return ticker, nil
+	}
+	t := res.Result
+	return &Ticker{
+		Symbol:        symbol(base, quote),
+		Last:          t.Last,
+		Bid:           t.Bid,
+		Ask:           t.Ask,
+		Open:          t.Open,
+		High:          t.High,
+		Low:           t.Low,
+		BaseVolume:    t.Volume,
+		QuoteVolume:   t.Deal,
+		ChangePercent: t.Change,
+		Time:          time.Now().UTC(),
+	}, nil
+}
+
 func (c *P2BConnector) CurrencyBalance(currency string) (available, freeze float64, err error) {
 	req := &p2pb2b.AccountCurrencyBalanceRequest{Currency: currency}
 	resp, err := c.Client.PostCurrencyBalance(req)
@@ -224,46 +295,98 @@ func (c *P2BConnector) CurrencyBalance(currency string) (available, freeze float
 }
 
 func (c *P2BConnector) DealHistory(base, quote string, startTime, endTime int64) ([]*Level, error) {
-	var offset int64 = 0
-	var limit int64 = 100
+	deals, err := c.deals(base, quote, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	levels := make([]*Level, 0, len(deals))
+	for _, d := range deals {
+		if d.IsSelfTrade {
+			continue
+		}
+		level, err := DealToLevel(d)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// deals все сделки аккаунта по паре за период, постранично
+func (c *P2BConnector) deals(base, quote string, startTime, endTime int64) ([]p2pb2b.DealHistoryEntry, error) {
 	req := &p2pb2b.DealsHistoryByMarketRequest{
 		Market:    symbol(base, quote),
 		StartTime: startTime,
 		EndTime:   endTime,
-		Offset:    offset,
-		Limit:     limit,
+		Offset:    0,
+		Limit:     100,
 	}
-	levels := make([]*Level, 0, 1)
-	res, err := c.Client.DealsHistoryByMarket(req)
+	deals := make([]p2pb2b.DealHistoryEntry, 0)
+	for {
+		res, err := c.Client.DealsHistoryByMarket(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(res.Result.Deals) == 0 {
+			return deals, nil
+		}
+		deals = append(deals, res.Result.Deals...)
+		req.Offset += req.Limit
+	}
+}
+
+// OrderByID открытый ордер из QueryUnexecuted. Закрытые ордера P2B не отдает, поэтому исполнение
+// закрытого считается по сделкам аккаунта с его deal_order_id за p2bOrderLookupWindow.
+// Цена и объем закрытого ордера неизвестны: в BaseAmount записывается исполненный объем
+func (c *P2BConnector) OrderByID(orderId, base, quote string, basePrecision, pricePrecision int) (*NetOrder, error) {
+	open, err := c.AllOpenOrders(base, quote, basePrecision, pricePrecision)
 	if err != nil {
 		return nil, err
 	}
-	for _, d := range res.Result.Deals {
-		if !d.IsSelfTrade {
-			level, err := DealToLevel(d)
-			if err != nil {
-				return nil, err
-			}
-			levels = append(levels, level)
+	for _, order := range open {
+		if order.ID() == orderId {
+			return order, nil
 		}
 	}
-	for res.Result.Deals != nil && len(res.Result.Deals) != 0 {
-		req.Offset += 100
-		res, err = c.Client.DealsHistoryByMarket(req)
+	id, err := strconv.ParseInt(orderId, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("order id %q: %w", orderId, err)
+	}
+	now := time.Now()
+	deals, err := c.deals(base, quote, now.Add(-p2bOrderLookupWindow).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	var filled float64
+	var side Side
+	for _, d := range deals {
+		if d.DealOrderID != id {
+			continue
+		}
+		amount, err := strconv.ParseFloat(d.Amount, 64)
 		if err != nil {
 			return nil, err
 		}
-		for _, d := range res.Result.Deals {
-			if !d.IsSelfTrade {
-				level, err := DealToLevel(d)
-				if err != nil {
-					return nil, err
-				}
-				levels = append(levels, level)
-			}
+		filled += amount
+		side = Sell
+		if d.Side == P2BBuy {
+			side = Buy
 		}
 	}
-	return levels, nil
+	filled = Round(filled, basePrecision)
+	return newNetOrder(&NetOrderConfig{
+		Id:           orderId,
+		ExName:       P2PB2B,
+		Symbol:       symbol(base, quote),
+		OrderType:    Limit,
+		Side:         side,
+		Status:       Closed,
+		BaseAmount:   filled,
+		FilledAmount: filled,
+		BasePrec:     basePrecision,
+		PricePrec:    pricePrecision,
+	}), nil
 }
 
 func DealToLevel(d p2pb2b.DealHistoryEntry) (*Level, error) {
@@ -277,6 +400,8 @@ func DealToLevel(d p2pb2b.DealHistoryEntry) (*Level, error) {
 	}
 	level := &Level{
 		Price: price,
+		ID:    strconv.FormatInt(d.DealID, 10),
+		Time:  time.UnixMilli(int64(d.DealTime * 1000)).UTC(),
 	}
 	if d.Side == "sell" {
 		level.SellAmount = amount
@@ -247,3 +247,9 @@ func TestP2BConnector_BestBidBestAsk(t *testing.T) {
 	assert.NoError(t, err)
 	fmt.Println(bestBid, bestAsk)
 }
+
+func TestP2BTopPrice(t *testing.T) {
+	assert.Equal(t, 54.9, p2bTopPrice([][]float64{{54.9, 1}, {54.8, 2}}))
+	assert.Zero(t, p2bTopPrice(nil))
+	assert.Zero(t, p2bTopPrice([][]float64{}))
+}
@@ -0,0 +1,249 @@
+package exchange_models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	P2BWsURL      = "wss://apiws.p2pb2b.com/"
+	p2bDepthLimit = 100
+	p2bDepthGroup = "0"
+)
+
+// P2BStreamingConnector получает стакан и сделки через публичный websocket P2B.
+// Приватного канала у P2B нет, поэтому наши ордера опрашиваются через REST.
+// Сервер не нумерует обновления стакана: Sequence считается локально по полученным сообщениям,
+// и пропуск сообщения внутри соединения не виден. После каждого переподключения номер
+// пропускается, чтобы LocalOrderBook загрузил стакан заново
+type P2BStreamingConnector struct {
+	*P2BConnector
+	URL                string
+	OrdersPollInterval time.Duration
+
+	hub    *streamHub
+	mu     sync.Mutex
+	ws     *wsConn
+	lastId int64
+}
+
+type p2bWsRequest struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+	Id     int64         `json:"id"`
+}
+
+type p2bWsMessage struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type p2bWsDepth struct {
+	Asks [][]string `json:"asks"`
+	Bids [][]string `json:"bids"`
+}
+
+type p2bWsDeal struct {
+	Id     int64   `json:"id"`
+	Time   float64 `json:"time"`
+	Price  string  `json:"price"`
+	Amount string  `json:"amount"`
+	Type   string  `json:"type"`
+}
+
+func NewP2BStreamingConnector(publicKey, secretKey string) (*P2BStreamingConnector, error) {
+	connector, err := NewP2BConnector(publicKey, secretKey)
+	if err != nil {
+		return nil, err
+	}
+	return &P2BStreamingConnector{
+		P2BConnector:       connector,
+		URL:                P2BWsURL,
+		OrdersPollInterval: defaultOrdersPollInterval,
+		hub:                newStreamHub(),
+	}, nil
+}
+
+func (c *P2BStreamingConnector) SubscribeOrderBook(base, quote string) (<-chan *BookUpdate, error) {
+	ch, err := c.hub.addBook(symbol(base, quote))
+	if err != nil {
+		return nil, err
+	}
+	return ch, c.send("depth.subscribe", symbol(base, quote), p2bDepthLimit, p2bDepthGroup)
+}
+
+func (c *P2BStreamingConnector) SubscribeTrades(base, quote string) (<-chan *Trade, error) {
+	ch, err := c.hub.addTrades(symbol(base, quote))
+	if err != nil {
+		return nil, err
+	}
+	return ch, c.send("deals.subscribe", symbol(base, quote))
+}
+
+func (c *P2BStreamingConnector) SubscribeOrders(base, quote string, basePrecision, pricePrecision int) (<-chan *NetOrder, error) {
+	return c.hub.pollOrders(c.P2BConnector, base, quote, basePrecision, pricePrecision, c.OrdersPollInterval)
+}
+
+func (c *P2BStreamingConnector) Errors() <-chan error {
+	return c.hub.errs
+}
+
+func (c *P2BStreamingConnector) Close() error {
+	c.hub.close(func() {
+		c.mu.Lock()
+		ws := c.ws
+		c.mu.Unlock()
+		if ws != nil {
+			ws.close()
+		}
+	})
+	return nil
+}
+
+func (c *P2BStreamingConnector) send(method string, params ...interface{}) error {
+	msg, err := c.request(method, params...)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if c.ws == nil {
+		c.ws = newWsConn(c.URL, c.handle, func() []byte {
+			ping, _ := c.request("server.ping")
+			return ping
+		}, c.hub.errs)
+		c.ws.onConnect = c.hub.skipSequences
+	}
+	ws := c.ws
+	c.mu.Unlock()
+	return ws.subscribe(msg)
+}
+
+func (c *P2BStreamingConnector) request(method string, params ...interface{}) ([]byte, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+	return json.Marshal(p2bWsRequest{
+		Method: method,
+		Params: params,
+		Id:     atomic.AddInt64(&c.lastId, 1),
+	})
+}
+
+func (c *P2BStreamingConnector) handle(raw []byte) {
+	var msg p2bWsMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		c.hub.reportError(err)
+		return
+	}
+	var err error
+	switch msg.Method {
+	case "depth.update":
+		err = c.handleDepth(msg.Params)
+	case "deals.update":
+		err = c.handleDeals(msg.Params)
+	}
+	if err != nil {
+		c.hub.reportError(err)
+	}
+}
+
+func (c *P2BStreamingConnector) handleDepth(params []json.RawMessage) error {
+	if len(params) < 3 {
+		return fmt.Errorf("unexpected depth.update params: %d", len(params))
+	}
+	var full bool
+	var depth p2bWsDepth
+	var market string
+	if err := json.Unmarshal(params[0], &full); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(params[1], &depth); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(params[2], &market); err != nil {
+		return err
+	}
+	update := &BookUpdate{
+		Symbol:   market,
+		Snapshot: full,
+		Sequence: c.hub.nextSequence(market),
+		Time:     time.Now().UTC(),
+	}
+	var err error
+	if update.Bids, err = p2bWsLevels(depth.Bids, Buy); err != nil {
+		return err
+	}
+	if update.Asks, err = p2bWsLevels(depth.Asks, Sell); err != nil {
+		return err
+	}
+	c.hub.publishBook(update)
+	return nil
+}
+
+func p2bWsLevels(rows [][]string, side Side) ([]*Level, error) {
+	levels := make([]*Level, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 2 {
+			return nil, fmt.Errorf("unexpected depth row: %v", row)
+		}
+		price, err := strconv.ParseFloat(row[0], 64)
+		if err != nil {
+			return nil, err
+		}
+		amount, err := strconv.ParseFloat(row[1], 64)
+		if err != nil {
+			return nil, err
+		}
+		level := &Level{Price: price}
+		if side == Buy {
+			level.BuyAmount = amount
+		} else {
+			level.SellAmount = amount
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+func (c *P2BStreamingConnector) handleDeals(params []json.RawMessage) error {
+	if len(params) < 2 {
+		return fmt.Errorf("unexpected deals.update params: %d", len(params))
+	}
+	var market string
+	var deals []p2bWsDeal
+	if err := json.Unmarshal(params[0], &market); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(params[1], &deals); err != nil {
+		return err
+	}
+	// P2B присылает сделки от новых к старым
+	for i := len(deals) - 1; i >= 0; i-- {
+		d := deals[i]
+		price, err := strconv.ParseFloat(d.Price, 64)
+		if err != nil {
+			return err
+		}
+		amount, err := strconv.ParseFloat(d.Amount, 64)
+		if err != nil {
+			return err
+		}
+		side := Buy
+		if d.Type == P2BSell {
+			side = Sell
+		}
+		c.hub.publishTrade(&Trade{
+			ID:     strconv.FormatInt(d.Id, 10),
+			Symbol: market,
+			Side:   side,
+			Price:  price,
+			Amount: amount,
+			Time:   time.UnixMilli(int64(d.Time * 1000)).UTC(),
+		})
+	}
+	return nil
+}
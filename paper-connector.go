@@ -0,0 +1,258 @@
+package exchange_models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type PaperConfig struct {
+	FillModel  FillModel // по умолчанию QueueFillModel
+	MakerFee   float64
+	TakerFee   float64
+	Balances   map[string]float64 // начальные виртуальные балансы
+	DepthLimit int64              // уровней стакана на сторону для исполнения, по умолчанию 50
+	Interval   time.Duration      // период Sync для Run
+	OnError    func(err error)    // ошибки Sync в Run
+}
+
+// paperSymbol пара, по которой выставлялись виртуальные ордера
+type paperSymbol struct {
+	base           string
+	quote          string
+	basePrecision  int
+	pricePrecision int
+	lastSync       time.Time
+	seen           map[string]time.Time // уже примененные сделки
+}
+
+// PaperConnector бумажная торговля: рыночные данные берутся с настоящей биржи,
+// ордера и балансы виртуальные и исполняются matchingEngine по реальным сделкам и стакану
+type PaperConnector struct {
+	connector Connector
+	config    *PaperConfig
+	engine    *matchingEngine
+
+	mu      sync.Mutex
+	symbols map[string]*paperSymbol
+}
+
+var (
+	_ Connector   = (*PaperConnector)(nil)
+	_ OrderLookup = (*PaperConnector)(nil)
+)
+
+func NewPaperConnector(c Connector, config *PaperConfig) (*PaperConnector, error) {
+	if config.MakerFee < 0 || config.TakerFee < 0 {
+		return nil, fmt.Errorf("fees must not be negative, got maker %f, taker %f", config.MakerFee, config.TakerFee)
+	}
+	for currency, amount := range config.Balances {
+		if amount < 0 {
+			return nil, fmt.Errorf("balance of %s must not be negative, got %f", currency, amount)
+		}
+	}
+	config = configCopy(config)
+	if config.DepthLimit <= 0 {
+		config.DepthLimit = 50
+	}
+	if config.Interval <= 0 {
+		config.Interval = 5 * time.Second
+	}
+	p := &PaperConnector{connector: c, config: config, symbols: make(map[string]*paperSymbol)}
+	p.engine = newMatchingEngine(config.FillModel, config.MakerFee, config.TakerFee, time.Now)
+	for currency, amount := range config.Balances {
+		p.engine.deposit(currency, amount)
+	}
+	return p, nil
+}
+
+// Fills исполнения виртуальных ордеров
+func (p *PaperConnector) Fills() []*SimFill {
+	return p.engine.fillsCopy()
+}
+
+// Run вызывает Sync раз в Interval до отмены ctx, чтобы ордера исполнялись
+// и без обращений бота к AllOpenOrders или CurrencyBalance
+func (p *PaperConnector) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		if err := p.Sync(); err != nil && p.config.OnError != nil {
+			p.config.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync применяет к виртуальным ордерам новые сделки и текущий стакан по всем парам
+func (p *PaperConnector) Sync() error {
+	p.mu.Lock()
+	symbols := make([]*paperSymbol, 0, len(p.symbols))
+	for _, s := range p.symbols {
+		symbols = append(symbols, s)
+	}
+	p.mu.Unlock()
+	var errs []error
+	for _, s := range symbols {
+		if err := p.sync(s); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", symbol(s.base, s.quote), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *PaperConnector) track(base, quote string, basePrecision, pricePrecision int) *paperSymbol {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.symbols[symbol(base, quote)]
+	if !ok {
+		s = &paperSymbol{
+			base:           base,
+			quote:          quote,
+			basePrecision:  basePrecision,
+			pricePrecision: pricePrecision,
+			lastSync:       time.Now(),
+			seen:           make(map[string]time.Time),
+		}
+		p.symbols[symbol(base, quote)] = s
+	}
+	return s
+}
+
+func (p *PaperConnector) syncSymbol(base, quote string) error {
+	p.mu.Lock()
+	s, ok := p.symbols[symbol(base, quote)]
+	p.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return p.sync(s)
+}
+
+// sync сначала применяет сделки с прошлой синхронизации, потом стакан.
+// Сделки без времени считаются новыми, повторы отсеиваются по id
+func (p *PaperConnector) sync(s *paperSymbol) error {
+	p.mu.Lock()
+	start := s.lastSync
+	p.mu.Unlock()
+	now := time.Now()
+	deals, err := p.connector.DealHistory(s.base, s.quote, start.UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("deal history: %w", err)
+	}
+	book, err := p.connector.Depth(s.base, s.quote, s.basePrecision, s.pricePrecision, p.config.DepthLimit)
+	if err != nil {
+		return fmt.Errorf("depth: %w", err)
+	}
+
+	p.mu.Lock()
+	fresh := make([]*Level, 0, len(deals))
+	for _, deal := range deals {
+		if !deal.Time.IsZero() && deal.Time.Before(start) {
+			continue
+		}
+		key := deal.ID
+		if key == "" {
+			key = fmt.Sprintf("%d/%f/%f/%f", deal.Time.UnixNano(), deal.Price, deal.BuyAmount, deal.SellAmount)
+		}
+		if _, ok := s.seen[key]; ok {
+			continue
+		}
+		s.seen[key] = deal.Time
+		fresh = append(fresh, deal)
+	}
+	// сделки на границе окна придут еще раз, старые id можно забыть
+	for key, t := range s.seen {
+		if !t.IsZero() && t.Before(start) {
+			delete(s.seen, key)
+		}
+	}
+	s.lastSync = now
+	p.mu.Unlock()
+
+	sort.SliceStable(fresh, func(i, j int) bool { return fresh[i].Time.Before(fresh[j].Time) })
+	for _, deal := range fresh {
+		p.engine.onTrade(s.base, s.quote, deal)
+	}
+	p.engine.onBook(s.base, s.quote, book)
+	return nil
+}
+
+// PostLimitOrder выставляет виртуальный ордер. Перед этим подтягивается стакан,
+// чтобы пересекающая его часть исполнилась как taker по текущим ценам
+func (p *PaperConnector) PostLimitOrder(base, quote string, side Side, baseAmount, price float64, basePrecision, pricePrecision int) (string, error) {
+	if err := p.sync(p.track(base, quote, basePrecision, pricePrecision)); err != nil {
+		return "", err
+	}
+	return p.engine.post(base, quote, side, baseAmount, price, basePrecision, pricePrecision)
+}
+
+func (p *PaperConnector) CancelOrder(orderId, base, quote string) error {
+	return p.engine.cancel(orderId)
+}
+
+// OrderByID виртуальный ордер после применения новых рыночных данных, в том числе закрытый
+func (p *PaperConnector) OrderByID(orderId, base, quote string, basePrecision, pricePrecision int) (*NetOrder, error) {
+	if err := p.syncSymbol(base, quote); err != nil {
+		return nil, err
+	}
+	return p.engine.order(orderId)
+}
+
+func (p *PaperConnector) AllOpenOrders(base, quote string, basePrecision, pricePrecision int) ([]*NetOrder, error) {
+	if err := p.syncSymbol(base, quote); err != nil {
+		return nil, err
+	}
+	return p.engine.openOrders(base, quote), nil
+}
+
+func (p *PaperConnector) OpenOrders(base, quote string, basePrecision, pricePrecision int, offset, limit int64) ([]*NetOrder, error) {
+	if err := p.syncSymbol(base, quote); err != nil {
+		return nil, err
+	}
+	return page(p.engine.openOrders(base, quote), offset, limit), nil
+}
+
+// CurrencyBalance виртуальный баланс после применения новых рыночных данных
+func (p *PaperConnector) CurrencyBalance(currency string) (available, freeze float64, err error) {
+	if err = p.Sync(); err != nil {
+		return 0, 0, err
+	}
+	available, freeze = p.engine.currencyBalance(currency)
+	return available, freeze, nil
+}
+
+func (p *PaperConnector) OrderBook(base, quote string, side Side, basePrecision, pricePrecision int, offset, limit int64) ([]*NetOrder, error) {
+	return p.connector.OrderBook(base, quote, side, basePrecision, pricePrecision, offset, limit)
+}
+
+func (p *PaperConnector) FullOrderBook(base, quote string, side Side, basePrecision, pricePrecision int) ([]*NetOrder, error) {
+	return p.connector.FullOrderBook(base, quote, side, basePrecision, pricePrecision)
+}
+
+func (p *PaperConnector) Depth(base, quote string, basePrecision, pricePrecision int, limit int64) (*OrderBookSnapshot, error) {
+	return p.connector.Depth(base, quote, basePrecision, pricePrecision, limit)
+}
+
+func (p *PaperConnector) BestBidBestAsk(base, quote string) (bestBid, bestAsk float64, err error) {
+	return p.connector.BestBidBestAsk(base, quote)
+}
+
+func (p *PaperConnector) LastPrice(base, quote string) (float64, error) {
+	return p.connector.LastPrice(base, quote)
+}
+
+func (p *PaperConnector) Ticker(base, quote string) (*Ticker, error) {
+	return p.connector.Ticker(base, quote)
+}
+
+func (p *PaperConnector) DealHistory(base, quote string, startTime, endTime int64) ([]*Level, error) {
+	return p.connector.DealHistory(base, quote, startTime, endTime)
+}
@@ -0,0 +1,140 @@
+package exchange_models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaperConnector(t *testing.T) {
+	mock := newMockConnector()
+	mock.buyBook = []*NetOrder{mockBookOrder(Buy, 99, 3)}
+	mock.sellBook = []*NetOrder{mockBookOrder(Sell, 101, 3)}
+	mock.bestBid, mock.bestAsk, mock.lastPrice = 99, 101, 100
+	p, err := NewPaperConnector(mock, &PaperConfig{
+		Balances: map[string]float64{"usdt": 1000, "btc": 1},
+		MakerFee: 0.001,
+		TakerFee: 0.002,
+	})
+	require.NoError(t, err)
+
+	bid, ask, err := p.BestBidBestAsk("btc", "usdt")
+	require.NoError(t, err)
+	assert.Equal(t, 99.0, bid)
+	assert.Equal(t, 101.0, ask)
+
+	id, err := p.PostLimitOrder("btc", "usdt", Buy, 1, 100, 3, 2)
+	require.NoError(t, err)
+	assert.Empty(t, mock.openOrders, "paper orders must not reach the exchange")
+	available, freeze, err := p.CurrencyBalance("usdt")
+	require.NoError(t, err)
+	assert.InDelta(t, 1000-100*1.002, available, 1e-9)
+	assert.InDelta(t, 100*1.002, freeze, 1e-9)
+
+	// сделка по нашей цене исполняет часть ордера, повтор той же сделки игнорируется
+	mock.deals = []*Level{{ID: "d1", Price: 100, SellAmount: 0.4}}
+	orders, err := p.AllOpenOrders("btc", "usdt", 3, 2)
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, id, orders[0].ID())
+	assert.InDelta(t, 0.4, orders[0].FilledAmount(), 1e-9)
+	orders, err = p.AllOpenOrders("btc", "usdt", 3, 2)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.4, orders[0].FilledAmount(), 1e-9)
+
+	// стакан прошел через нашу цену, остаток исполняется
+	mock.sellBook = []*NetOrder{mockBookOrder(Sell, 99.5, 3)}
+	orders, err = p.AllOpenOrders("btc", "usdt", 3, 2)
+	require.NoError(t, err)
+	assert.Empty(t, orders)
+	// исполненный ордер находится по id
+	order, err := p.OrderByID(id, "btc", "usdt", 3, 2)
+	require.NoError(t, err)
+	assert.Equal(t, Filled, order.Status())
+	assert.InDelta(t, 1, order.FilledAmount(), 1e-9)
+
+	available, freeze, err = p.CurrencyBalance("btc")
+	require.NoError(t, err)
+	assert.InDelta(t, 2, available, 1e-9)
+	assert.Zero(t, freeze)
+	available, _, err = p.CurrencyBalance("usdt")
+	require.NoError(t, err)
+	assert.InDelta(t, 900-100*0.001, available, 1e-9)
+
+	// продажа через лучший бид исполняется сразу как taker
+	_, err = p.PostLimitOrder("btc", "usdt", Sell, 0.5, 98, 3, 2)
+	require.NoError(t, err)
+	available, _, err = p.CurrencyBalance("btc")
+	require.NoError(t, err)
+	assert.InDelta(t, 1.5, available, 1e-9)
+	fills := p.Fills()
+	require.Len(t, fills, 3)
+	assert.True(t, fills[2].Taker)
+	assert.Equal(t, 99.0, fills[2].Price)
+
+	_, err = p.PostLimitOrder("btc", "usdt", Buy, 100, 50, 3, 2)
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+
+	id, err = p.PostLimitOrder("btc", "usdt", Sell, 0.5, 120, 3, 2)
+	require.NoError(t, err)
+	require.NoError(t, p.CancelOrder(id, "btc", "usdt"))
+	available, freeze, err = p.CurrencyBalance("btc")
+	require.NoError(t, err)
+	assert.InDelta(t, 1.5, available, 1e-9)
+	assert.Zero(t, freeze)
+	order, err = p.OrderByID(id, "btc", "usdt", 3, 2)
+	require.NoError(t, err)
+	assert.Equal(t, Cancelled, order.Status())
+	_, err = p.OrderByID("missing", "btc", "usdt", 3, 2)
+	assert.Error(t, err)
+}
+
+func TestPaperConnector_BookLiquidity(t *testing.T) {
+	mock := newMockConnector()
+	mock.buyBook = []*NetOrder{mockBookOrder(Buy, 99, 1)}
+	mock.sellBook = []*NetOrder{mockBookOrder(Sell, 101, 1)}
+	p, err := NewPaperConnector(mock, &PaperConfig{Balances: map[string]float64{"usdt": 1000, "btc": 5}})
+	require.NoError(t, err)
+
+	// уровень 99 отдает только 1 btc, сколько бы раз стакан ни загружался
+	_, err = p.PostLimitOrder("btc", "usdt", Sell, 0.6, 99, 3, 2)
+	require.NoError(t, err)
+	_, err = p.PostLimitOrder("btc", "usdt", Sell, 0.6, 99, 3, 2)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, p.Sync())
+	}
+	orders, err := p.AllOpenOrders("btc", "usdt", 3, 2)
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.InDelta(t, 0.4, orders[0].FilledAmount(), 1e-9)
+
+	// уровень изменился, его объем снова доступен
+	mock.buyBook = []*NetOrder{mockBookOrder(Buy, 99, 2)}
+	_, err = p.PostLimitOrder("btc", "usdt", Sell, 0.6, 99, 3, 2)
+	require.NoError(t, err)
+	orders, err = p.AllOpenOrders("btc", "usdt", 3, 2)
+	require.NoError(t, err)
+	assert.Len(t, orders, 1)
+	fills := p.Fills()
+	assert.InDelta(t, 0.6, fills[len(fills)-1].Amount, 1e-9)
+}
+
+func TestPaperConnector_BuyFeeReserve(t *testing.T) {
+	mock := newMockConnector()
+	mock.sellBook = []*NetOrder{mockBookOrder(Sell, 100, 5)}
+	p, err := NewPaperConnector(mock, &PaperConfig{Balances: map[string]float64{"usdt": 100}, TakerFee: 0.002})
+	require.NoError(t, err)
+
+	// на покупку всего баланса не хватает на комиссию
+	_, err = p.PostLimitOrder("btc", "usdt", Buy, 1, 100, 3, 2)
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+	_, err = p.PostLimitOrder("btc", "usdt", Buy, 0.998, 100, 3, 2)
+	require.NoError(t, err)
+	available, freeze, err := p.CurrencyBalance("usdt")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, available, 0.0)
+	assert.InDelta(t, 100-99.8*1.002, available, 1e-9)
+	assert.InDelta(t, 0, freeze, 1e-9)
+}
@@ -0,0 +1,212 @@
+package exchange_models
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+type ActionType string
+
+var (
+	CancelAction ActionType = "Cancel"
+	PostAction   ActionType = "Post"
+	AmendAction  ActionType = "Amend" // отмена и выставление по той же цене с новым объемом
+)
+
+var ErrCrossedNet = errors.New("net is crossed: best buy price is not below best sell price")
+
+type NetAction struct {
+	Type  ActionType
+	Order *NetOrder // отменяемый ордер для Cancel и Amend
+	New   *NetOrder // выставляемый ордер для Post и Amend
+}
+
+// PlanConfig допуски сравнения цен и объемов. Нулевой допуск берется из точности ордера
+type PlanConfig struct {
+	PriceEps    float64
+	AmountEps   float64
+	CancelFirst bool // все отмены до выставлений, чтобы новые ордера не ждали средств, замороженных в старых
+}
+
+type NetPlan struct {
+	Actions []*NetAction
+}
+
+// PlanNet считает минимальный список действий, переводящий current в desired.
+// Ордера, совпадающие по цене и неисполненному объему, остаются на месте.
+// Сначала отменяются ордера, которые пересекутся с новыми, затем выставляются новые,
+// потом исправляются объемы и в конце отменяются остальные лишние ордера,
+// так что наши покупки ни на одном шаге не оказываются выше продаж. С CancelFirst
+// все отмены идут первыми, пересечений при этом тоже не бывает
+func PlanNet(current, desired *ClassicNet, config *PlanConfig) (*NetPlan, error) {
+	if config == nil {
+		config = &PlanConfig{}
+	}
+	if netCrossed(desired.Orders(Buy), desired.Orders(Sell)) {
+		return nil, ErrCrossedNet
+	}
+	var posts, amends, cancels []*NetAction
+	for _, side := range []Side{Buy, Sell} {
+		p, a, c := planSide(current.Orders(side), desired.Orders(side), config)
+		posts = append(posts, p...)
+		amends = append(amends, a...)
+		cancels = append(cancels, c...)
+	}
+	plan := &NetPlan{Actions: make([]*NetAction, 0, len(posts)+len(amends)+len(cancels))}
+	late := make([]*NetAction, 0, len(cancels))
+	for _, cancel := range cancels {
+		if config.CancelFirst || crossesAny(cancel.Order, posts) {
+			plan.Actions = append(plan.Actions, cancel)
+		} else {
+			late = append(late, cancel)
+		}
+	}
+	plan.Actions = append(plan.Actions, posts...)
+	plan.Actions = append(plan.Actions, amends...)
+	plan.Actions = append(plan.Actions, late...)
+	return plan, nil
+}
+
+func planSide(current, desired []*NetOrder, config *PlanConfig) (posts, amends, cancels []*NetAction) {
+	matched := make([]bool, len(current))
+	unmatched := make([]*NetOrder, 0)
+	for _, want := range desired {
+		found := false
+		for idx, have := range current {
+			if !matched[idx] && samePrice(have, want, config) && sameAmount(have, want, config) {
+				matched[idx] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			unmatched = append(unmatched, want)
+		}
+	}
+	for _, want := range unmatched {
+		found := false
+		for idx, have := range current {
+			if !matched[idx] && samePrice(have, want, config) {
+				matched[idx] = true
+				found = true
+				amends = append(amends, &NetAction{Type: AmendAction, Order: have, New: want})
+				break
+			}
+		}
+		if !found {
+			posts = append(posts, &NetAction{Type: PostAction, New: want})
+		}
+	}
+	for idx, have := range current {
+		if !matched[idx] {
+			cancels = append(cancels, &NetAction{Type: CancelAction, Order: have})
+		}
+	}
+	return
+}
+
+func samePrice(have, want *NetOrder, config *PlanConfig) bool {
+	eps := config.PriceEps
+	if eps == 0 {
+		eps = precisionEps(want.PricePrecision())
+	}
+	return Equals(have.Price(), want.Price(), eps)
+}
+
+func sameAmount(have, want *NetOrder, config *PlanConfig) bool {
+	eps := config.AmountEps
+	if eps == 0 {
+		eps = precisionEps(want.BasePrecision())
+	}
+	return Equals(have.UnfilledAmount(), want.BaseAmount(), eps)
+}
+
+func crossesAny(order *NetOrder, posts []*NetAction) bool {
+	for _, post := range posts {
+		if post.New.Side() == order.Side() {
+			continue
+		}
+		if order.Side() == Buy && order.Price() >= post.New.Price() ||
+			order.Side() == Sell && order.Price() <= post.New.Price() {
+			return true
+		}
+	}
+	return false
+}
+
+func netCrossed(buyOrders, sellOrders []*NetOrder) bool {
+	if len(buyOrders) == 0 || len(sellOrders) == 0 {
+		return false
+	}
+	maxBuy := buyOrders[0].Price()
+	for _, order := range buyOrders {
+		if order.Price() > maxBuy {
+			maxBuy = order.Price()
+		}
+	}
+	for _, order := range sellOrders {
+		if order.Price() <= maxBuy {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *NetPlan) Empty() bool {
+	return len(p.Actions) == 0
+}
+
+// Execute выполняет план на бирже и поддерживает current в актуальном состоянии.
+// Amend выполняется как отмена и выставление нового ордера. В current встает копия
+// нового ордера с биржевым id, ордера желаемой сети не меняются
+func (p *NetPlan) Execute(c Connector, base, quote string, current *ClassicNet) error {
+	for _, action := range p.Actions {
+		if action.Type == CancelAction || action.Type == AmendAction {
+			if err := c.CancelOrder(action.Order.ID(), base, quote); err != nil {
+				return fmt.Errorf("cancel %s: %w", action.Order.ID(), err)
+			}
+			// ордера уже может не быть в сети, тогда менять нечего
+			_, _ = current.UpdateOrder(action.Order.ID(), func(order *NetOrder) {
+				order.SetStatus(Cancelled).SetDeathDate(time.Now().UTC())
+			})
+		}
+		if action.Type == PostAction || action.Type == AmendAction {
+			order := action.New.clone()
+			id, err := c.PostLimitOrder(base, quote, order.Side(), order.BaseAmount(), order.Price(), order.BasePrecision(), order.PricePrecision())
+			if err != nil {
+				return fmt.Errorf("post %s %f at %f: %w", order.Side(), order.BaseAmount(), order.Price(), err)
+			}
+			order.SetID(id).SetStatus(New).SetCreationDate(time.Now().UTC())
+			if action.Type == AmendAction {
+				order.SetPreviousId(action.Order.ID())
+			}
+			current.InsertOrder(order)
+		}
+	}
+	return nil
+}
+
+// Print печатает план без выполнения
+func (p *NetPlan) Print() {
+	p.Fprint(os.Stdout)
+}
+
+func (p *NetPlan) Fprint(w io.Writer) {
+	if p.Empty() {
+		fmt.Fprintln(w, "nothing to do")
+		return
+	}
+	for idx, action := range p.Actions {
+		switch action.Type {
+		case CancelAction:
+			fmt.Fprintf(w, "%d. cancel %s %s, price: %f, amount: %f\n", idx+1, action.Order.Side(), action.Order.ID(), action.Order.Price(), action.Order.UnfilledAmount())
+		case PostAction:
+			fmt.Fprintf(w, "%d. post %s, price: %f, amount: %f\n", idx+1, action.New.Side(), action.New.Price(), action.New.BaseAmount())
+		case AmendAction:
+			fmt.Fprintf(w, "%d. amend %s %s, price: %f, amount: %f -> %f\n", idx+1, action.Order.Side(), action.Order.ID(), action.Order.Price(), action.Order.UnfilledAmount(), action.New.BaseAmount())
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package exchange_models
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestNet(orders ...*NetOrder) *ClassicNet {
+	net := NewEmptyClassicNet()
+	for _, order := range orders {
+		net.InsertOrder(order)
+	}
+	return net
+}
+
+func TestPlanNet(t *testing.T) {
+	current := newTestNet(
+		newTestOrder("1", Buy, 54, 1, 0),
+		newTestOrder("2", Buy, 53, 1, 0),
+		newTestOrder("3", Sell, 56, 1, 0),
+		newTestOrder("4", Sell, 57, 2, 0),
+		newTestOrder("5", Sell, 58, 1, 0),
+	)
+	// цены сдвигаются вверх: новая покупка по 56.5 пересекается с текущей продажей по 56
+	desired := newTestNet(
+		newTestOrder("", Buy, 56.5, 1, 0),
+		newTestOrder("", Buy, 54, 1, 0),
+		newTestOrder("", Sell, 57, 1, 0),
+		newTestOrder("", Sell, 58, 1.0001, 0),
+	)
+
+	plan, err := PlanNet(current, desired, nil)
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	plan.Fprint(&buf)
+	t.Log(buf.String())
+
+	types := make(map[ActionType]int)
+	for _, action := range plan.Actions {
+		types[action.Type]++
+	}
+	assert.Equal(t, 1, types[PostAction])
+	assert.Equal(t, 1, types[AmendAction])
+	assert.Equal(t, 2, types[CancelAction])
+	assert.Equal(t, CancelAction, plan.Actions[0].Type)
+	assert.Equal(t, "3", plan.Actions[0].Order.ID())
+	assert.Equal(t, PostAction, plan.Actions[1].Type)
+
+	// биржа выдает те же id 1-5 в порядке выставления
+	c := newMockConnector()
+	for _, order := range append(current.Orders(Buy), current.Orders(Sell)...) {
+		id, err := c.PostLimitOrder("SDFA", "USDT", order.Side(), order.BaseAmount(), order.Price(), 3, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, order.ID(), id)
+	}
+	for _, action := range plan.Actions {
+		step := &NetPlan{Actions: []*NetAction{action}}
+		assert.NoError(t, step.Execute(c, "SDFA", "USDT", current))
+		assert.False(t, netCrossed(current.Orders(Buy), current.Orders(Sell)))
+	}
+	// желаемая сеть не меняется: у ее ордеров нет биржевых id
+	for _, order := range append(desired.Orders(Buy), desired.Orders(Sell)...) {
+		assert.Empty(t, order.ID())
+		assert.Empty(t, order.PreviousId())
+	}
+	_, ok := desired.OrderByID("")
+	assert.True(t, ok)
+	_, ok = current.OrderByID("")
+	assert.False(t, ok)
+	plan, err = PlanNet(current, desired, nil)
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty())
+	live, err := c.AllOpenOrders("SDFA", "USDT", 3, 2)
+	assert.NoError(t, err)
+	assert.True(t, Reconcile(current, live).Empty())
+}
+
+func TestPlanNet_Tolerance(t *testing.T) {
+	current := newTestNet(newTestOrder("1", Buy, 54, 1, 0))
+	desired := newTestNet(newTestOrder("", Buy, 54.004, 1.0004, 0))
+	plan, err := PlanNet(current, desired, nil)
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty())
+
+	plan, err = PlanNet(current, desired, &PlanConfig{PriceEps: 0.001, AmountEps: 0.0001})
+	assert.NoError(t, err)
+	assert.Len(t, plan.Actions, 2)
+
+	plan, err = PlanNet(newTestNet(newTestOrder("1", Buy, 53, 1, 0), newTestOrder("2", Sell, 56, 1, 0)),
+		newTestNet(newTestOrder("", Buy, 52, 1, 0), newTestOrder("", Sell, 57, 1, 0)), &PlanConfig{CancelFirst: true})
+	assert.NoError(t, err)
+	assert.Len(t, plan.Actions, 4)
+	assert.Equal(t, CancelAction, plan.Actions[0].Type)
+	assert.Equal(t, CancelAction, plan.Actions[1].Type)
+
+	_, err = PlanNet(current, newTestNet(newTestOrder("", Buy, 55, 1, 0), newTestOrder("", Sell, 55, 1, 0)), nil)
+	assert.ErrorIs(t, err, ErrCrossedNet)
+}
@@ -0,0 +1,226 @@
+package exchange_models
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+type RebalanceConfig struct {
+	Quote         string                // валюта оценки портфеля, все сделки идут в парах к ней
+	Targets       map[string]float64    // желаемые доли валют, вместе с Quote сумма равна 1
+	Symbols       map[string]SymbolInfo // точности пар по валюте base
+	Tolerance     float64               // допустимое отклонение доли, например 0.02
+	PriceLimitBps float64               // покупки не дороже LastPrice на столько bps, продажи не дешевле
+	MinNotional   float64               // сделки меньше этого объема в quote пропускаются
+}
+
+type Allocation struct {
+	Currency string
+	Amount   float64
+	Price    float64 // в quote
+	Value    float64
+	Weight   float64
+	Target   float64
+}
+
+type RebalanceTrade struct {
+	Currency   string
+	Side       Side
+	Amount     float64
+	LimitPrice float64
+	ID         string // id выставленного ордера
+}
+
+// RebalanceReport распределение до и после ребалансировки. After считается по ценам LastPrice
+// в предположении, что все сделки исполнились
+type RebalanceReport struct {
+	Before []*Allocation
+	After  []*Allocation
+	Trades []*RebalanceTrade
+	Total  float64
+	Quote  string
+}
+
+// Rebalancer приводит доли валют портфеля к целевым лимитными ордерами
+type Rebalancer struct {
+	connector Connector
+	config    *RebalanceConfig
+}
+
+func NewRebalancer(c Connector, config *RebalanceConfig) (*Rebalancer, error) {
+	var sum float64
+	for currency, weight := range config.Targets {
+		if weight < 0 {
+			return nil, fmt.Errorf("target weight of %s must not be negative, got %f", currency, weight)
+		}
+		if _, ok := config.Symbols[currency]; !ok && currency != config.Quote {
+			return nil, fmt.Errorf("no symbol info for %s", currency)
+		}
+		sum += weight
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		return nil, fmt.Errorf("target weights must sum to 1, got %f", sum)
+	}
+	if config.Tolerance < 0 {
+		return nil, fmt.Errorf("tolerance must not be negative, got %f", config.Tolerance)
+	}
+	return &Rebalancer{connector: c, config: config}, nil
+}
+
+// Rebalance считает и выставляет сделки. Ошибки выставления отдельных ордеров
+// не останавливают остальные, в отчете остаются только выставленные сделки
+func (r *Rebalancer) Rebalance() (*RebalanceReport, error) {
+	report, err := r.Plan()
+	if err != nil {
+		return nil, err
+	}
+	return report, r.Execute(report)
+}
+
+// Plan считает сделки без выставления ордеров
+func (r *Rebalancer) Plan() (*RebalanceReport, error) {
+	c := r.config
+	currencies := make([]string, 0, len(c.Targets))
+	for currency := range c.Targets {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	report := &RebalanceReport{Before: make([]*Allocation, 0, len(currencies)), Quote: c.Quote}
+	var quoteAvailable float64
+	for _, currency := range currencies {
+		available, freeze, err := r.connector.CurrencyBalance(currency)
+		if err != nil {
+			return nil, fmt.Errorf("%s balance: %w", currency, err)
+		}
+		price := 1.0
+		if currency != c.Quote {
+			if price, err = r.connector.LastPrice(currency, c.Quote); err != nil {
+				return nil, fmt.Errorf("%s last price: %w", symbol(currency, c.Quote), err)
+			}
+			if price <= 0 {
+				return nil, fmt.Errorf("%s has no last price", symbol(currency, c.Quote))
+			}
+		} else {
+			quoteAvailable = available
+		}
+		amount := available + freeze
+		report.Before = append(report.Before, &Allocation{Currency: currency, Amount: amount, Price: price, Value: amount * price, Target: c.Targets[currency]})
+		report.Total += amount * price
+	}
+	setWeights(report.Before, report.Total)
+
+	buys := make([]*RebalanceTrade, 0)
+	for _, alloc := range report.Before {
+		if alloc.Currency == c.Quote || math.Abs(alloc.Weight-alloc.Target) <= c.Tolerance {
+			continue
+		}
+		info := c.Symbols[alloc.Currency]
+		amount := Floor(math.Abs(alloc.Target*report.Total-alloc.Value)/alloc.Price, info.BasePrecision)
+		trade := &RebalanceTrade{Currency: alloc.Currency, Side: Sell, Amount: amount}
+		offset := c.PriceLimitBps / 10000
+		if alloc.Weight < alloc.Target {
+			trade.Side = Buy
+			trade.LimitPrice = Round(alloc.Price*(1+offset), info.PricePrecision)
+		} else {
+			trade.LimitPrice = Round(alloc.Price*(1-offset), info.PricePrecision)
+		}
+		if trade.Amount <= 0 || trade.Amount*trade.LimitPrice < c.MinNotional {
+			continue
+		}
+		if trade.Side == Sell {
+			report.Trades = append(report.Trades, trade)
+		} else {
+			buys = append(buys, trade)
+		}
+	}
+	// покупки ограничены доступным quote, выручка от продаж еще не получена
+	for _, trade := range buys {
+		info := c.Symbols[trade.Currency]
+		if cost := trade.Amount * trade.LimitPrice; cost > quoteAvailable {
+			trade.Amount = Floor(quoteAvailable/trade.LimitPrice, info.BasePrecision)
+		}
+		if trade.Amount <= 0 || trade.Amount*trade.LimitPrice < c.MinNotional {
+			continue
+		}
+		quoteAvailable -= trade.Amount * trade.LimitPrice
+		report.Trades = append(report.Trades, trade)
+	}
+	report.after()
+	return report, nil
+}
+
+// Execute выставляет сделки отчета, продажи идут первыми
+func (r *Rebalancer) Execute(report *RebalanceReport) error {
+	var errs []error
+	placed := make([]*RebalanceTrade, 0, len(report.Trades))
+	for _, trade := range report.Trades {
+		info := r.config.Symbols[trade.Currency]
+		id, err := r.connector.PostLimitOrder(trade.Currency, r.config.Quote, trade.Side, trade.Amount, trade.LimitPrice, info.BasePrecision, info.PricePrecision)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s %f at %f: %w", trade.Side, trade.Currency, trade.Amount, trade.LimitPrice, err))
+			continue
+		}
+		trade.ID = id
+		placed = append(placed, trade)
+	}
+	report.Trades = placed
+	report.after()
+	return errors.Join(errs...)
+}
+
+func (r *RebalanceReport) after() {
+	amounts := make(map[string]float64, len(r.Before))
+	for _, alloc := range r.Before {
+		amounts[alloc.Currency] = alloc.Amount
+	}
+	var quoteDelta float64
+	for _, trade := range r.Trades {
+		if trade.Side == Buy {
+			amounts[trade.Currency] += trade.Amount
+			quoteDelta -= trade.Amount * trade.LimitPrice
+		} else {
+			amounts[trade.Currency] -= trade.Amount
+			quoteDelta += trade.Amount * trade.LimitPrice
+		}
+	}
+	r.After = make([]*Allocation, 0, len(r.Before))
+	var total float64
+	for _, before := range r.Before {
+		amount := amounts[before.Currency]
+		if before.Currency == r.Quote {
+			amount += quoteDelta
+		}
+		r.After = append(r.After, &Allocation{Currency: before.Currency, Amount: amount, Price: before.Price, Value: amount * before.Price, Target: before.Target})
+		total += amount * before.Price
+	}
+	setWeights(r.After, total)
+}
+
+func setWeights(allocations []*Allocation, total float64) {
+	if total <= 0 {
+		return
+	}
+	for _, alloc := range allocations {
+		alloc.Weight = alloc.Value / total
+	}
+}
+
+func (r *RebalanceReport) Print() {
+	r.Fprint(os.Stdout)
+}
+
+func (r *RebalanceReport) Fprint(w io.Writer) {
+	fmt.Fprintf(w, "total: %f\n", r.Total)
+	for idx, before := range r.Before {
+		after := r.After[idx]
+		fmt.Fprintf(w, "%s: %f (%.2f%%) -> %f (%.2f%%), target %.2f%%\n", before.Currency,
+			before.Amount, before.Weight*100, after.Amount, after.Weight*100, before.Target*100)
+	}
+	for _, trade := range r.Trades {
+		fmt.Fprintf(w, "%s %f %s at %f, id: %s\n", trade.Side, trade.Amount, trade.Currency, trade.LimitPrice, trade.ID)
+	}
+}
@@ -0,0 +1,71 @@
+package exchange_models
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pricedConnector struct {
+	*mockConnector
+	prices map[string]float64
+}
+
+func (c *pricedConnector) LastPrice(base, quote string) (float64, error) {
+	return c.prices[base], nil
+}
+
+func TestRebalancer(t *testing.T) {
+	c := &pricedConnector{mockConnector: newMockConnector(), prices: map[string]float64{"BTC": 50000, "SDFA": 100}}
+	c.balances["BTC"], c.balances["SDFA"], c.balances["USDT"] = 0.1, 20, 1000
+	rebalancer, err := NewRebalancer(c, &RebalanceConfig{
+		Quote:   "USDT",
+		Targets: map[string]float64{"BTC": 0.5, "SDFA": 0.3, "USDT": 0.2},
+		Symbols: map[string]SymbolInfo{
+			"BTC":  {Base: "BTC", Quote: "USDT", BasePrecision: 5, PricePrecision: 1},
+			"SDFA": {Base: "SDFA", Quote: "USDT", BasePrecision: 3, PricePrecision: 2},
+		},
+		Tolerance:     0.02,
+		PriceLimitBps: 10,
+		MinNotional:   5,
+	})
+	assert.NoError(t, err)
+
+	// всего 5000 + 2000 + 1000 = 8000: BTC 62.5%, SDFA 25%, USDT 12.5%
+	report, err := rebalancer.Rebalance()
+	assert.NoError(t, err)
+	assert.Equal(t, 8000.0, report.Total)
+	assert.Equal(t, 0.625, report.Before[0].Weight)
+	assert.Len(t, report.Trades, 2)
+	sell, buy := report.Trades[0], report.Trades[1]
+	assert.Equal(t, Sell, sell.Side)
+	assert.Equal(t, "BTC", sell.Currency)
+	assert.Equal(t, 0.02, sell.Amount)
+	assert.Equal(t, 49950.0, sell.LimitPrice)
+	assert.Equal(t, Buy, buy.Side)
+	assert.Equal(t, "SDFA", buy.Currency)
+	assert.Equal(t, 4.0, buy.Amount)
+	assert.Equal(t, 100.1, buy.LimitPrice)
+	assert.Len(t, c.openOrders, 2)
+	assert.NotEmpty(t, sell.ID)
+	for _, alloc := range report.After {
+		assert.InDelta(t, alloc.Target, alloc.Weight, 0.01, alloc.Currency)
+	}
+	var buf bytes.Buffer
+	report.Fprint(&buf)
+	t.Log(buf.String())
+
+	// покупки ограничены доступным quote
+	c.balances["USDT"] = 100
+	report, err = rebalancer.Plan()
+	assert.NoError(t, err)
+	for _, trade := range report.Trades {
+		if trade.Side == Buy {
+			assert.LessOrEqual(t, trade.Amount*trade.LimitPrice, 100.0)
+		}
+	}
+
+	_, err = NewRebalancer(c, &RebalanceConfig{Quote: "USDT", Targets: map[string]float64{"USDT": 0.5}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,198 @@
+package exchange_models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+type FillUpdate struct {
+	Order        *NetOrder // ордер из сети
+	FilledAmount float64   // исполненный объем по данным биржи
+}
+
+type OrderMismatch struct {
+	Local *NetOrder
+	Live  *NetOrder
+}
+
+// ReconcileDiff расхождения между локальной сетью и открытыми ордерами на бирже
+type ReconcileDiff struct {
+	Filled          []*FillUpdate
+	PartiallyFilled []*FillUpdate
+	Missing         []*NetOrder // есть в сети, нет на бирже: исполнены или отменены не нами
+	Unknown         []*NetOrder // есть на бирже, нет в сети
+	Mismatched      []*OrderMismatch
+}
+
+// Reconcile сравнивает сеть с живыми ордерами, сеть не меняется
+func Reconcile(net *ClassicNet, live []*NetOrder) *ReconcileDiff {
+	diff := &ReconcileDiff{}
+	liveById := make(map[string]*NetOrder, len(live))
+	for _, order := range live {
+		liveById[order.ID()] = order
+	}
+	local := make([]*NetOrder, 0, len(net.Orders(Buy))+len(net.Orders(Sell)))
+	local = append(local, net.Orders(Buy)...)
+	local = append(local, net.Orders(Sell)...)
+	localIds := make(map[string]bool, len(local))
+	for _, order := range local {
+		localIds[order.ID()] = true
+		liveOrder, ok := liveById[order.ID()]
+		if !ok {
+			diff.Missing = append(diff.Missing, order)
+			continue
+		}
+		if !sameOrderParams(order, liveOrder) {
+			diff.Mismatched = append(diff.Mismatched, &OrderMismatch{Local: order, Live: liveOrder})
+			continue
+		}
+		eps := precisionEps(order.BasePrecision())
+		switch {
+		case liveOrder.Status() == Filled || liveOrder.FilledAmount() >= order.BaseAmount()-eps:
+			diff.Filled = append(diff.Filled, &FillUpdate{Order: order, FilledAmount: order.BaseAmount()})
+		case liveOrder.FilledAmount() > order.FilledAmount()+eps:
+			diff.PartiallyFilled = append(diff.PartiallyFilled, &FillUpdate{Order: order, FilledAmount: liveOrder.FilledAmount()})
+		}
+	}
+	for _, order := range live {
+		if !localIds[order.ID()] {
+			diff.Unknown = append(diff.Unknown, order)
+		}
+	}
+	return diff
+}
+
+// ReconcileWithExchange сравнивает сеть с AllOpenOrders коннектора
+func ReconcileWithExchange(c Connector, net *ClassicNet, base, quote string, basePrecision, pricePrecision int) (*ReconcileDiff, error) {
+	live, err := c.AllOpenOrders(base, quote, basePrecision, pricePrecision)
+	if err != nil {
+		return nil, err
+	}
+	return Reconcile(net, live), nil
+}
+
+// ConfirmFills делит пропавшие с биржи ордера на исполненные полностью и остальные.
+// Исполнение подтверждает только OrderLookup коннектора, без него все ордера попадают в остальные
+func ConfirmFills(c Connector, base, quote string, missing []*NetOrder) (filled, unconfirmed []*NetOrder, err error) {
+	final, err := FinalFilled(c, base, quote, missing)
+	if errors.Is(err, ErrFilledUnknown) {
+		return nil, missing, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, order := range missing {
+		if final[order.ID()] >= order.BaseAmount()-precisionEps(order.BasePrecision()) {
+			filled = append(filled, order)
+		} else {
+			unconfirmed = append(unconfirmed, order)
+		}
+	}
+	return filled, unconfirmed, nil
+}
+
+// ErrFilledUnknown коннектор не находит закрытые ордера, и их итоговое исполнение неизвестно
+var ErrFilledUnknown = errors.New("filled amount is unknown: connector has no order lookup")
+
+// FinalFilled итоговый исполненный объем закрытых или пропавших ордеров по id из OrderLookup,
+// но не меньше уже известного. Остальные биржи отдают только открытые ордера,
+// для них возвращается ErrFilledUnknown
+func FinalFilled(c Connector, base, quote string, orders []*NetOrder) (map[string]float64, error) {
+	res := make(map[string]float64, len(orders))
+	if len(orders) == 0 {
+		return res, nil
+	}
+	lookup, ok := c.(OrderLookup)
+	if !ok {
+		return nil, ErrFilledUnknown
+	}
+	for _, order := range orders {
+		live, err := lookup.OrderByID(order.ID(), base, quote, order.BasePrecision(), order.PricePrecision())
+		if err != nil {
+			return nil, fmt.Errorf("order %s: %w", order.ID(), err)
+		}
+		res[order.ID()] = math.Max(order.FilledAmount(), live.FilledAmount())
+	}
+	return res, nil
+}
+
+func (d *ReconcileDiff) Empty() bool {
+	return len(d.Filled) == 0 && len(d.PartiallyFilled) == 0 && len(d.Missing) == 0 &&
+		len(d.Unknown) == 0 && len(d.Mismatched) == 0
+}
+
+// Apply приводит сеть к состоянию биржи: исполненные и пропавшие ордера удаляются,
+// частичные исполнения записываются, расходящиеся ордера заменяются биржевыми.
+// Неизвестные ордера добавляются в сеть только при adoptUnknown.
+// Исполнения и закрытия проходят через UpdateOrder, поэтому подписчики сети получают события
+func (d *ReconcileDiff) Apply(net *ClassicNet, adoptUnknown bool) error {
+	now := time.Now().UTC()
+	fills := make([]*FillUpdate, 0, len(d.PartiallyFilled)+len(d.Filled))
+	fills = append(fills, d.PartiallyFilled...)
+	fills = append(fills, d.Filled...)
+	for _, fill := range fills {
+		var fillErr error
+		_, err := net.UpdateOrder(fill.Order.ID(), func(order *NetOrder) {
+			if amount := fill.FilledAmount - order.FilledAmount(); amount > 0 {
+				fillErr = order.AddFilledAmount(amount)
+			}
+			if order.UnfilledAmount() > 0 && order.FilledAmount() > 0 {
+				order.SetStatus(PartiallyFilled)
+			}
+		})
+		if err = errors.Join(err, fillErr); err != nil {
+			return fmt.Errorf("order %s: %w", fill.Order.ID(), err)
+		}
+	}
+	for _, order := range d.Missing {
+		_, err := net.UpdateOrder(order.ID(), func(order *NetOrder) {
+			order.SetStatus(Closed).SetDeathDate(now)
+		})
+		if err != nil {
+			return fmt.Errorf("order %s: %w", order.ID(), err)
+		}
+	}
+	for _, mismatch := range d.Mismatched {
+		if mismatch.Live.PreviousId() == "" {
+			mismatch.Live.SetPreviousId(mismatch.Local.PreviousId())
+		}
+		if mismatch.Live.CreationDate().IsZero() {
+			mismatch.Live.SetCreationDate(mismatch.Local.CreationDate())
+		}
+		net.RemoveOrder(mismatch.Local.ID())
+		net.InsertOrder(mismatch.Live)
+	}
+	if adoptUnknown {
+		for _, order := range d.Unknown {
+			net.InsertOrder(order)
+		}
+	}
+	return nil
+}
+
+func (d *ReconcileDiff) Print() {
+	for _, fill := range d.Filled {
+		fmt.Printf("filled: %s\n", fill.Order.ID())
+	}
+	for _, fill := range d.PartiallyFilled {
+		fmt.Printf("partially filled: %s, %f -> %f\n", fill.Order.ID(), fill.Order.FilledAmount(), fill.FilledAmount)
+	}
+	for _, order := range d.Missing {
+		fmt.Printf("missing: %s\n", order.ID())
+	}
+	for _, order := range d.Unknown {
+		fmt.Printf("unknown: %s\n", order.ID())
+	}
+	for _, mismatch := range d.Mismatched {
+		fmt.Printf("mismatched: %s, price %f -> %f, base amount %f -> %f\n", mismatch.Local.ID(),
+			mismatch.Local.Price(), mismatch.Live.Price(), mismatch.Local.BaseAmount(), mismatch.Live.BaseAmount())
+	}
+}
+
+func sameOrderParams(local, live *NetOrder) bool {
+	return local.Side() == live.Side() &&
+		Equals(local.Price(), live.Price(), precisionEps(local.PricePrecision())) &&
+		Equals(local.BaseAmount(), live.BaseAmount(), precisionEps(local.BasePrecision()))
+}
@@ -0,0 +1,121 @@
+package exchange_models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestOrder(id string, side Side, price, baseAmount, filledAmount float64) *NetOrder {
+	order, _ := NewNetOrder(&NetOrderConfig{
+		Id:           id,
+		Symbol:       "SDFA_USDT",
+		Side:         side,
+		OrderType:    Limit,
+		Status:       New,
+		Price:        price,
+		BaseAmount:   baseAmount,
+		FilledAmount: filledAmount,
+		BasePrec:     3,
+		PricePrec:    2,
+	})
+	return order
+}
+
+func TestReconcile(t *testing.T) {
+	net := NewEmptyClassicNet()
+	net.InsertOrder(newTestOrder("1", Buy, 54, 1, 0))
+	net.InsertOrder(newTestOrder("2", Buy, 53, 2, 0))
+	net.InsertOrder(newTestOrder("3", Sell, 56, 1, 0))
+	net.InsertOrder(newTestOrder("4", Sell, 57, 1, 0.2))
+	net.InsertOrder(newTestOrder("5", Sell, 58, 1, 0))
+
+	live := []*NetOrder{
+		newTestOrder("1", Buy, 54, 1, 0),
+		newTestOrder("2", Buy, 53, 2, 0.5),
+		newTestOrder("4", Sell, 57, 1, 1),
+		newTestOrder("5", Sell, 58.5, 1, 0),
+		newTestOrder("9", Sell, 60, 3, 0),
+	}
+
+	diff := Reconcile(net, live)
+	assert.False(t, diff.Empty())
+	assert.Len(t, diff.PartiallyFilled, 1)
+	assert.Equal(t, "2", diff.PartiallyFilled[0].Order.ID())
+	assert.Equal(t, 0.5, diff.PartiallyFilled[0].FilledAmount)
+	assert.Len(t, diff.Filled, 1)
+	assert.Equal(t, "4", diff.Filled[0].Order.ID())
+	assert.Len(t, diff.Missing, 1)
+	assert.Equal(t, "3", diff.Missing[0].ID())
+	assert.Len(t, diff.Unknown, 1)
+	assert.Equal(t, "9", diff.Unknown[0].ID())
+	assert.Len(t, diff.Mismatched, 1)
+	assert.Equal(t, 58.5, diff.Mismatched[0].Live.Price())
+	diff.Print()
+
+	// изменения приходят подписчикам сети
+	events := make(map[string]*NetEvent)
+	unsubscribe := net.Subscribe(func(event *NetEvent) {
+		if event.Order != nil {
+			events[event.Order.ID()] = event
+		}
+	})
+	assert.NoError(t, diff.Apply(net, false))
+	unsubscribe()
+	assert.Equal(t, OrderFilled, events["2"].Type)
+	assert.Equal(t, OrderFilled, events["4"].Type)
+	assert.Equal(t, Filled, events["4"].Order.Status())
+	assert.Equal(t, OrderRemoved, events["3"].Type)
+	assert.Equal(t, Closed, events["3"].Order.Status())
+	assert.Len(t, net.Orders(Buy), 2)
+	assert.Equal(t, 0.5, net.Orders(Buy)[1].FilledAmount())
+	assert.Equal(t, PartiallyFilled, net.Orders(Buy)[1].Status())
+	assert.Len(t, net.Orders(Sell), 1)
+	assert.Equal(t, 58.5, net.Orders(Sell)[0].Price())
+	// в diff лежат копии, Apply меняет только сеть
+	assert.Equal(t, New, diff.Missing[0].Status())
+	assert.Equal(t, New, diff.Filled[0].Order.Status())
+
+	diff = Reconcile(net, []*NetOrder{live[0], live[1], live[3], live[4]})
+	assert.Len(t, diff.Unknown, 1)
+	assert.NoError(t, diff.Apply(net, true))
+	assert.Len(t, net.Orders(Sell), 2)
+	assert.True(t, Reconcile(net, []*NetOrder{live[0], live[1], live[3], live[4]}).Empty())
+}
+
+func TestReconcileWithExchange(t *testing.T) {
+	c := newMockConnector()
+	id, err := c.PostLimitOrder("SDFA", "USDT", Buy, 1, 54, 3, 2)
+	assert.NoError(t, err)
+	net := NewEmptyClassicNet()
+	net.InsertOrder(newTestOrder(id, Buy, 54, 1, 0))
+
+	diff, err := ReconcileWithExchange(c, net, "SDFA", "USDT", 3, 2)
+	assert.NoError(t, err)
+	assert.True(t, diff.Empty())
+
+	assert.NoError(t, c.CancelOrder(id, "SDFA", "USDT"))
+	diff, err = ReconcileWithExchange(c, net, "SDFA", "USDT", 3, 2)
+	assert.NoError(t, err)
+	assert.Len(t, diff.Missing, 1)
+}
+
+func TestConfirmFills(t *testing.T) {
+	c := &lookupConnector{newMockConnector()}
+	buy := newTestOrder("1", Buy, 54, 1, 0.4)
+	sell := newTestOrder("2", Sell, 56, 1, 0)
+	c.closed["1"] = newTestOrder("1", Buy, 54, 1, 1).SetStatus(Filled)
+	c.closed["2"] = newTestOrder("2", Sell, 56, 1, 0.5).SetStatus(CancelledNotFully)
+	// сделки по цене ордера исполнения не подтверждают
+	c.deals = []*Level{{Price: 56, BuyAmount: 1, Time: time.Now().UTC()}}
+	filled, unconfirmed, err := ConfirmFills(c, "SDFA", "USDT", []*NetOrder{buy, sell})
+	assert.NoError(t, err)
+	assert.Equal(t, []*NetOrder{buy}, filled)
+	assert.Equal(t, []*NetOrder{sell}, unconfirmed)
+
+	filled, unconfirmed, err = ConfirmFills(c.mockConnector, "SDFA", "USDT", []*NetOrder{buy, sell})
+	assert.NoError(t, err)
+	assert.Empty(t, filled)
+	assert.Equal(t, []*NetOrder{buy, sell}, unconfirmed)
+}
@@ -0,0 +1,374 @@
+package exchange_models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+var ErrNoRecordedCall = errors.New("no recorded call")
+
+// RecordedCall вызов коннектора, строка файла записи в формате JSON lines
+type RecordedCall struct {
+	Method string          `json:"method"`
+	Args   json.RawMessage `json:"args"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Code   string          `json:"code,omitempty"` // код известной ошибки пакета, см. recordedErrors
+}
+
+// recordedErrors ошибки пакета, которые пишутся кодом и восстанавливаются при воспроизведении,
+// чтобы errors.Is работал так же, как с настоящим коннектором
+var recordedErrors = []struct {
+	code string
+	err  error
+}{
+	{"insufficient_balance", ErrInsufficientBalance},
+	{"stream_closed", ErrStreamClosed},
+	{"no_recorded_call", ErrNoRecordedCall},
+	{"below_min_notional", ErrBelowMinNotional},
+	{"infeasible_split", ErrInfeasibleSplit},
+	{"crossed_net", ErrCrossedNet},
+	{"unhedged_leg", ErrUnhedgedLeg},
+}
+
+func errorCode(err error) string {
+	for _, known := range recordedErrors {
+		if errors.Is(err, known.err) {
+			return known.code
+		}
+	}
+	return ""
+}
+
+// replayedError записанная ошибка с исходным текстом, которая оборачивает известную ошибку пакета
+type replayedError struct {
+	msg string
+	err error
+}
+
+func (e *replayedError) Error() string {
+	return e.msg
+}
+
+func (e *replayedError) Unwrap() error {
+	return e.err
+}
+
+func recordedError(call *RecordedCall) error {
+	for _, known := range recordedErrors {
+		if known.code == call.Code {
+			return &replayedError{msg: call.Error, err: known.err}
+		}
+	}
+	return errors.New(call.Error)
+}
+
+// RecordingConnector пишет каждый вызов обернутого коннектора вместе с ответом в w
+type RecordingConnector struct {
+	connector Connector
+
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+var _ Connector = (*RecordingConnector)(nil)
+
+func NewRecordingConnector(c Connector, w io.Writer) *RecordingConnector {
+	return &RecordingConnector{connector: c, enc: json.NewEncoder(w)}
+}
+
+// Err первая ошибка записи
+func (c *RecordingConnector) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *RecordingConnector) record(method string, args []any, result any, callErr error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	call := &RecordedCall{Method: method}
+	if call.Args, c.err = json.Marshal(args); c.err != nil {
+		return
+	}
+	if callErr != nil {
+		call.Error = callErr.Error()
+		call.Code = errorCode(callErr)
+	} else if result != nil {
+		if call.Result, c.err = json.Marshal(result); c.err != nil {
+			return
+		}
+	}
+	c.err = c.enc.Encode(call)
+}
+
+func (c *RecordingConnector) PostLimitOrder(base, quote string, side Side, baseAmount, price float64, basePrecision, pricePrecision int) (string, error) {
+	id, err := c.connector.PostLimitOrder(base, quote, side, baseAmount, price, basePrecision, pricePrecision)
+	c.record("PostLimitOrder", []any{base, quote, side, baseAmount, price, basePrecision, pricePrecision}, id, err)
+	return id, err
+}
+
+func (c *RecordingConnector) CancelOrder(orderId, base, quote string) error {
+	err := c.connector.CancelOrder(orderId, base, quote)
+	c.record("CancelOrder", []any{orderId, base, quote}, nil, err)
+	return err
+}
+
+func (c *RecordingConnector) AllOpenOrders(base, quote string, basePrecision, pricePrecision int) ([]*NetOrder, error) {
+	orders, err := c.connector.AllOpenOrders(base, quote, basePrecision, pricePrecision)
+	c.record("AllOpenOrders", []any{base, quote, basePrecision, pricePrecision}, orderConfigs(orders), err)
+	return orders, err
+}
+
+func (c *RecordingConnector) OpenOrders(base, quote string, basePrecision, pricePrecision int, offset, limit int64) ([]*NetOrder, error) {
+	orders, err := c.connector.OpenOrders(base, quote, basePrecision, pricePrecision, offset, limit)
+	c.record("OpenOrders", []any{base, quote, basePrecision, pricePrecision, offset, limit}, orderConfigs(orders), err)
+	return orders, err
+}
+
+func (c *RecordingConnector) OrderBook(base, quote string, side Side, basePrecision, pricePrecision int, offset, limit int64) ([]*NetOrder, error) {
+	orders, err := c.connector.OrderBook(base, quote, side, basePrecision, pricePrecision, offset, limit)
+	c.record("OrderBook", []any{base, quote, side, basePrecision, pricePrecision, offset, limit}, orderConfigs(orders), err)
+	return orders, err
+}
+
+func (c *RecordingConnector) FullOrderBook(base, quote string, side Side, basePrecision, pricePrecision int) ([]*NetOrder, error) {
+	orders, err := c.connector.FullOrderBook(base, quote, side, basePrecision, pricePrecision)
+	c.record("FullOrderBook", []any{base, quote, side, basePrecision, pricePrecision}, orderConfigs(orders), err)
+	return orders, err
+}
+
+func (c *RecordingConnector) Depth(base, quote string, basePrecision, pricePrecision int, limit int64) (*OrderBookSnapshot, error) {
+	book, err := c.connector.Depth(base, quote, basePrecision, pricePrecision, limit)
+	c.record("Depth", []any{base, quote, basePrecision, pricePrecision, limit}, book, err)
+	return book, err
+}
+
+func (c *RecordingConnector) BestBidBestAsk(base, quote string) (bestBid, bestAsk float64, err error) {
+	bestBid, bestAsk, err = c.connector.BestBidBestAsk(base, quote)
+	c.record("BestBidBestAsk", []any{base, quote}, []float64{bestBid, bestAsk}, err)
+	return bestBid, bestAsk, err
+}
+
+func (c *RecordingConnector) LastPrice(base, quote string) (float64, error) {
+	price, err := c.connector.LastPrice(base, quote)
+	c.record("LastPrice", []any{base, quote}, price, err)
+	return price, err
+}
+
+func (c *RecordingConnector) Ticker(base, quote string) (*Ticker, error) {
+	ticker, err := c.connector.Ticker(base, quote)
+	c.record("Ticker", []any{base, quote}, ticker, err)
+	return ticker, err
+}
+
+func (c *RecordingConnector) DealHistory(base, quote string, startTime, endTime int64) ([]*Level, error) {
+	deals, err := c.connector.DealHistory(base, quote, startTime, endTime)
+	c.record("DealHistory", []any{base, quote, startTime, endTime}, deals, err)
+	return deals, err
+}
+
+func (c *RecordingConnector) CurrencyBalance(currency string) (available, freeze float64, err error) {
+	available, freeze, err = c.connector.CurrencyBalance(currency)
+	c.record("CurrencyBalance", []any{currency}, []float64{available, freeze}, err)
+	return available, freeze, err
+}
+
+type ReplayMode int
+
+const (
+	ReplayInOrder ReplayMode = iota // вызовы должны идти в записанном порядке с теми же аргументами
+	ReplayByArgs                    // берется первый неиспользованный вызов с тем же методом и аргументами
+)
+
+// ReplayConnector отдает записанные RecordingConnector ответы без обращения к бирже
+type ReplayConnector struct {
+	mode ReplayMode
+
+	mu    sync.Mutex
+	calls []*RecordedCall
+	used  []bool
+	next  int
+}
+
+var _ Connector = (*ReplayConnector)(nil)
+
+func NewReplayConnector(r io.Reader, mode ReplayMode) (*ReplayConnector, error) {
+	c := &ReplayConnector{mode: mode}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		call := &RecordedCall{}
+		if err := json.Unmarshal(scanner.Bytes(), call); err != nil {
+			return nil, fmt.Errorf("recorded call %d: %w", len(c.calls)+1, err)
+		}
+		c.calls = append(c.calls, call)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	c.used = make([]bool, len(c.calls))
+	return c, nil
+}
+
+func LoadReplayConnector(path string, mode ReplayMode) (*ReplayConnector, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewReplayConnector(f, mode)
+}
+
+// Remaining количество неиспользованных вызовов
+func (c *ReplayConnector) Remaining() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var res int
+	for _, used := range c.used {
+		if !used {
+			res++
+		}
+	}
+	return res
+}
+
+// replay находит записанный вызов и раскладывает его ответ в result
+func (c *ReplayConnector) replay(method string, args []any, result any) error {
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	idx := -1
+	if c.mode == ReplayInOrder {
+		if c.next >= len(c.calls) {
+			return fmt.Errorf("%w: %s%s after the end of the record", ErrNoRecordedCall, method, encoded)
+		}
+		call := c.calls[c.next]
+		if call.Method != method || !bytes.Equal(call.Args, encoded) {
+			return fmt.Errorf("%w: %s%s, recorded %s%s", ErrNoRecordedCall, method, encoded, call.Method, call.Args)
+		}
+		idx = c.next
+		c.next++
+	} else {
+		for i, call := range c.calls {
+			if !c.used[i] && call.Method == method && bytes.Equal(call.Args, encoded) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return fmt.Errorf("%w: %s%s", ErrNoRecordedCall, method, encoded)
+		}
+	}
+	c.used[idx] = true
+	call := c.calls[idx]
+	if call.Error != "" {
+		return recordedError(call)
+	}
+	if result == nil || len(call.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(call.Result, result)
+}
+
+func (c *ReplayConnector) PostLimitOrder(base, quote string, side Side, baseAmount, price float64, basePrecision, pricePrecision int) (string, error) {
+	var id string
+	err := c.replay("PostLimitOrder", []any{base, quote, side, baseAmount, price, basePrecision, pricePrecision}, &id)
+	return id, err
+}
+
+func (c *ReplayConnector) CancelOrder(orderId, base, quote string) error {
+	return c.replay("CancelOrder", []any{orderId, base, quote}, nil)
+}
+
+func (c *ReplayConnector) replayOrders(method string, args []any) ([]*NetOrder, error) {
+	var configs []*NetOrderConfig
+	if err := c.replay(method, args, &configs); err != nil {
+		return nil, err
+	}
+	orders := make([]*NetOrder, 0, len(configs))
+	for _, config := range configs {
+		order, err := NewNetOrder(config)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
+
+func (c *ReplayConnector) AllOpenOrders(base, quote string, basePrecision, pricePrecision int) ([]*NetOrder, error) {
+	return c.replayOrders("AllOpenOrders", []any{base, quote, basePrecision, pricePrecision})
+}
+
+func (c *ReplayConnector) OpenOrders(base, quote string, basePrecision, pricePrecision int, offset, limit int64) ([]*NetOrder, error) {
+	return c.replayOrders("OpenOrders", []any{base, quote, basePrecision, pricePrecision, offset, limit})
+}
+
+func (c *ReplayConnector) OrderBook(base, quote string, side Side, basePrecision, pricePrecision int, offset, limit int64) ([]*NetOrder, error) {
+	return c.replayOrders("OrderBook", []any{base, quote, side, basePrecision, pricePrecision, offset, limit})
+}
+
+func (c *ReplayConnector) FullOrderBook(base, quote string, side Side, basePrecision, pricePrecision int) ([]*NetOrder, error) {
+	return c.replayOrders("FullOrderBook", []any{base, quote, side, basePrecision, pricePrecision})
+}
+
+func (c *ReplayConnector) Depth(base, quote string, basePrecision, pricePrecision int, limit int64) (*OrderBookSnapshot, error) {
+	var book *OrderBookSnapshot
+	err := c.replay("Depth", []any{base, quote, basePrecision, pricePrecision, limit}, &book)
+	return book, err
+}
+
+func (c *ReplayConnector) BestBidBestAsk(base, quote string) (bestBid, bestAsk float64, err error) {
+	var prices [2]float64
+	err = c.replay("BestBidBestAsk", []any{base, quote}, &prices)
+	return prices[0], prices[1], err
+}
+
+func (c *ReplayConnector) LastPrice(base, quote string) (float64, error) {
+	var price float64
+	err := c.replay("LastPrice", []any{base, quote}, &price)
+	return price, err
+}
+
+func (c *ReplayConnector) Ticker(base, quote string) (*Ticker, error) {
+	var ticker *Ticker
+	err := c.replay("Ticker", []any{base, quote}, &ticker)
+	return ticker, err
+}
+
+func (c *ReplayConnector) DealHistory(base, quote string, startTime, endTime int64) ([]*Level, error) {
+	var deals []*Level
+	err := c.replay("DealHistory", []any{base, quote, startTime, endTime}, &deals)
+	return deals, err
+}
+
+func (c *ReplayConnector) CurrencyBalance(currency string) (available, freeze float64, err error) {
+	var balance [2]float64
+	err = c.replay("CurrencyBalance", []any{currency}, &balance)
+	return balance[0], balance[1], err
+}
+
+func orderConfigs(orders []*NetOrder) []*NetOrderConfig {
+	res := make([]*NetOrderConfig, 0, len(orders))
+	for _, order := range orders {
+		res = append(res, orderConfig(order))
+	}
+	return res
+}
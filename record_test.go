@@ -0,0 +1,139 @@
+package exchange_models
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recordMockSession(t *testing.T) *bytes.Buffer {
+	mock := newMockConnector()
+	mock.bestBid, mock.bestAsk, mock.lastPrice = 99, 101, 100
+	mock.balances["usdt"] = 500
+	mock.sellBook = []*NetOrder{mockBookOrder(Sell, 101, 2)}
+
+	buf := &bytes.Buffer{}
+	c := NewRecordingConnector(mock, buf)
+	id, err := c.PostLimitOrder("btc", "usdt", Buy, 1.5, 98, 3, 2)
+	require.NoError(t, err)
+	_, err = c.AllOpenOrders("btc", "usdt", 3, 2)
+	require.NoError(t, err)
+	_, _, err = c.BestBidBestAsk("btc", "usdt")
+	require.NoError(t, err)
+	_, err = c.LastPrice("btc", "usdt")
+	require.NoError(t, err)
+	_, err = c.Depth("btc", "usdt", 3, 2, 10)
+	require.NoError(t, err)
+	_, _, err = c.CurrencyBalance("usdt")
+	require.NoError(t, err)
+	require.NoError(t, c.CancelOrder(id, "btc", "usdt"))
+	assert.Error(t, c.CancelOrder(id, "btc", "usdt"))
+	require.NoError(t, c.Err())
+	return buf
+}
+
+func TestReplayConnector_InOrder(t *testing.T) {
+	c, err := NewReplayConnector(recordMockSession(t), ReplayInOrder)
+	require.NoError(t, err)
+
+	id, err := c.PostLimitOrder("btc", "usdt", Buy, 1.5, 98, 3, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "1", id)
+
+	orders, err := c.AllOpenOrders("btc", "usdt", 3, 2)
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, "1", orders[0].ID())
+	assert.Equal(t, Buy, orders[0].Side())
+	assert.Equal(t, 98.0, orders[0].Price())
+	assert.Equal(t, 1.5, orders[0].BaseAmount())
+
+	// вызов не по порядку не сдвигает запись
+	_, err = c.LastPrice("btc", "usdt")
+	assert.ErrorIs(t, err, ErrNoRecordedCall)
+
+	bid, ask, err := c.BestBidBestAsk("btc", "usdt")
+	require.NoError(t, err)
+	assert.Equal(t, 99.0, bid)
+	assert.Equal(t, 101.0, ask)
+
+	price, err := c.LastPrice("btc", "usdt")
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, price)
+
+	book, err := c.Depth("btc", "usdt", 3, 2, 10)
+	require.NoError(t, err)
+	require.Len(t, book.Asks, 1)
+	assert.Equal(t, 101.0, book.Asks[0].Price)
+	assert.Equal(t, 2.0, book.Asks[0].Amount)
+
+	available, freeze, err := c.CurrencyBalance("usdt")
+	require.NoError(t, err)
+	assert.Equal(t, 500.0, available)
+	assert.Equal(t, 0.0, freeze)
+
+	require.NoError(t, c.CancelOrder("1", "btc", "usdt"))
+	err = c.CancelOrder("1", "btc", "usdt")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "order 1 not found")
+	assert.Equal(t, 0, c.Remaining())
+
+	_, err = c.LastPrice("btc", "usdt")
+	assert.ErrorIs(t, err, ErrNoRecordedCall)
+}
+
+func TestReplayConnector_ByArgs(t *testing.T) {
+	c, err := NewReplayConnector(recordMockSession(t), ReplayByArgs)
+	require.NoError(t, err)
+
+	available, _, err := c.CurrencyBalance("usdt")
+	require.NoError(t, err)
+	assert.Equal(t, 500.0, available)
+
+	_, _, err = c.CurrencyBalance("btc")
+	assert.ErrorIs(t, err, ErrNoRecordedCall)
+
+	// одинаковые вызовы отдаются в порядке записи
+	require.NoError(t, c.CancelOrder("1", "btc", "usdt"))
+	assert.Error(t, c.CancelOrder("1", "btc", "usdt"))
+	err = c.CancelOrder("1", "btc", "usdt")
+	assert.ErrorIs(t, err, ErrNoRecordedCall)
+
+	price, err := c.LastPrice("btc", "usdt")
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, price)
+	assert.Equal(t, 4, c.Remaining())
+}
+
+// brokeConnector отвечает на выставление ордера ошибкой баланса
+type brokeConnector struct {
+	*mockConnector
+}
+
+func (c *brokeConnector) PostLimitOrder(base, quote string, side Side, baseAmount, price float64, basePrecision, pricePrecision int) (string, error) {
+	return "", fmt.Errorf("%w: %s 0, need %f", ErrInsufficientBalance, quote, baseAmount*price)
+}
+
+func TestReplayConnector_KnownErrors(t *testing.T) {
+	buf := &bytes.Buffer{}
+	rec := NewRecordingConnector(&brokeConnector{newMockConnector()}, buf)
+	_, recordedErr := rec.PostLimitOrder("btc", "usdt", Buy, 1, 100, 3, 2)
+	require.ErrorIs(t, recordedErr, ErrInsufficientBalance)
+	assert.Error(t, rec.CancelOrder("1", "btc", "usdt"))
+	require.NoError(t, rec.Err())
+
+	c, err := NewReplayConnector(buf, ReplayByArgs)
+	require.NoError(t, err)
+	_, err = c.PostLimitOrder("btc", "usdt", Buy, 1, 100, 3, 2)
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+	assert.EqualError(t, err, recordedErr.Error())
+
+	// неизвестная ошибка воспроизводится только текстом
+	err = c.CancelOrder("1", "btc", "usdt")
+	assert.EqualError(t, err, "order 1 not found")
+	assert.False(t, errors.Is(err, ErrInsufficientBalance))
+}
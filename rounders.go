@@ -17,3 +17,8 @@ func Ceil(number float64, precision int) float64 {
 func Equals(n1, n2, eps float64) bool {
 	return math.Abs(n1-n2) < eps
 }
+
+// precisionEps половина шага точности, числа ближе друг к другу равны после Round
+func precisionEps(precision int) float64 {
+	return math.Pow10(-precision) / 2
+}
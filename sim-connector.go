@@ -0,0 +1,263 @@
+package exchange_models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type SimConfig struct {
+	Base           string
+	Quote          string
+	BasePrecision  int
+	PricePrecision int
+	Deals          []*Level             // записанные сделки DealHistory, нужны Time
+	Books          []*OrderBookSnapshot // записанные стаканы, нужны Time
+	FillModel      FillModel            // по умолчанию QueueFillModel
+	MakerFee       float64
+	TakerFee       float64
+	Balances       map[string]float64 // начальные балансы
+}
+
+// SimConnector биржа на записанных данных с виртуальными часами. Рыночные данные отдаются
+// на момент часов, наши ордера исполняются matchingEngine при движении часов в Advance
+type SimConnector struct {
+	config *SimConfig
+	engine *matchingEngine
+
+	mu       sync.Mutex
+	now      time.Time
+	deals    []*Level
+	books    []*OrderBookSnapshot
+	dealIdx  int // сделки до dealIdx уже произошли
+	bookIdx  int // стаканы до bookIdx уже применены
+	lastBook *OrderBookSnapshot
+}
+
+var (
+	_ Connector   = (*SimConnector)(nil)
+	_ OrderLookup = (*SimConnector)(nil)
+)
+
+func NewSimConnector(config *SimConfig) (*SimConnector, error) {
+	deals := append([]*Level(nil), config.Deals...)
+	books := append([]*OrderBookSnapshot(nil), config.Books...)
+	for _, deal := range deals {
+		if deal.Time.IsZero() {
+			return nil, fmt.Errorf("deal %s has no time", deal.ID)
+		}
+	}
+	sort.SliceStable(deals, func(i, j int) bool { return deals[i].Time.Before(deals[j].Time) })
+	sort.SliceStable(books, func(i, j int) bool { return books[i].Time.Before(books[j].Time) })
+	c := &SimConnector{config: config, deals: deals, books: books}
+	c.engine = newMatchingEngine(config.FillModel, config.MakerFee, config.TakerFee, c.Now)
+	for currency, amount := range config.Balances {
+		c.engine.deposit(currency, amount)
+	}
+	switch {
+	case len(deals) > 0 && len(books) > 0 && books[0].Time.Before(deals[0].Time):
+		c.now = books[0].Time
+	case len(deals) > 0:
+		c.now = deals[0].Time
+	case len(books) > 0:
+		c.now = books[0].Time
+	}
+	return c, nil
+}
+
+// Now время виртуальных часов
+func (c *SimConnector) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance двигает часы до t, по порядку времени применяя стаканы и сделки к нашим ордерам
+func (c *SimConnector) Advance(t time.Time) {
+	for {
+		c.mu.Lock()
+		var deal *Level
+		var book *OrderBookSnapshot
+		nextDeal := c.dealIdx < len(c.deals) && !c.deals[c.dealIdx].Time.After(t)
+		nextBook := c.bookIdx < len(c.books) && !c.books[c.bookIdx].Time.After(t)
+		switch {
+		case nextBook && (!nextDeal || !c.deals[c.dealIdx].Time.Before(c.books[c.bookIdx].Time)):
+			book = c.books[c.bookIdx]
+			c.bookIdx++
+			c.lastBook = book
+			c.now = book.Time
+		case nextDeal:
+			deal = c.deals[c.dealIdx]
+			c.dealIdx++
+			c.now = deal.Time
+		default:
+			if t.After(c.now) {
+				c.now = t
+			}
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+		if book != nil {
+			c.engine.onBook(c.config.Base, c.config.Quote, book)
+		} else {
+			c.engine.onTrade(c.config.Base, c.config.Quote, deal)
+		}
+	}
+}
+
+// End время последнего события в данных
+func (c *SimConnector) End() time.Time {
+	var end time.Time
+	if len(c.deals) > 0 {
+		end = c.deals[len(c.deals)-1].Time
+	}
+	if len(c.books) > 0 && c.books[len(c.books)-1].Time.After(end) {
+		end = c.books[len(c.books)-1].Time
+	}
+	return end
+}
+
+// Fills исполнения наших ордеров
+func (c *SimConnector) Fills() []*SimFill {
+	return c.engine.fillsCopy()
+}
+
+func (c *SimConnector) checkSymbol(base, quote string) error {
+	if base != c.config.Base || quote != c.config.Quote {
+		return fmt.Errorf("simulated exchange trades only %s, got %s", symbol(c.config.Base, c.config.Quote), symbol(base, quote))
+	}
+	return nil
+}
+
+func (c *SimConnector) PostLimitOrder(base, quote string, side Side, baseAmount, price float64, basePrecision, pricePrecision int) (string, error) {
+	if err := c.checkSymbol(base, quote); err != nil {
+		return "", err
+	}
+	return c.engine.post(base, quote, side, baseAmount, price, basePrecision, pricePrecision)
+}
+
+func (c *SimConnector) CancelOrder(orderId, base, quote string) error {
+	return c.engine.cancel(orderId)
+}
+
+// OrderByID находит и исполненные и снятые ордера
+func (c *SimConnector) OrderByID(orderId, base, quote string, basePrecision, pricePrecision int) (*NetOrder, error) {
+	if err := c.checkSymbol(base, quote); err != nil {
+		return nil, err
+	}
+	return c.engine.order(orderId)
+}
+
+func (c *SimConnector) AllOpenOrders(base, quote string, basePrecision, pricePrecision int) ([]*NetOrder, error) {
+	return c.engine.openOrders(base, quote), nil
+}
+
+func (c *SimConnector) OpenOrders(base, quote string, basePrecision, pricePrecision int, offset, limit int64) ([]*NetOrder, error) {
+	return page(c.engine.openOrders(base, quote), offset, limit), nil
+}
+
+func (c *SimConnector) book() *OrderBookSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastBook == nil {
+		return &OrderBookSnapshot{Symbol: symbol(c.config.Base, c.config.Quote), Time: c.now}
+	}
+	return c.lastBook
+}
+
+func (c *SimConnector) OrderBook(base, quote string, side Side, basePrecision, pricePrecision int, offset, limit int64) ([]*NetOrder, error) {
+	return page(bookOrders(c.book(), side, basePrecision, pricePrecision), offset, limit), nil
+}
+
+func (c *SimConnector) FullOrderBook(base, quote string, side Side, basePrecision, pricePrecision int) ([]*NetOrder, error) {
+	return bookOrders(c.book(), side, basePrecision, pricePrecision), nil
+}
+
+func (c *SimConnector) Depth(base, quote string, basePrecision, pricePrecision int, limit int64) (*OrderBookSnapshot, error) {
+	book := c.book()
+	res := &OrderBookSnapshot{Symbol: book.Symbol, Bids: book.Bids, Asks: book.Asks, Time: book.Time}
+	if limit > 0 && int64(len(res.Bids)) > limit {
+		res.Bids = res.Bids[:limit]
+	}
+	if limit > 0 && int64(len(res.Asks)) > limit {
+		res.Asks = res.Asks[:limit]
+	}
+	return res, nil
+}
+
+func (c *SimConnector) BestBidBestAsk(base, quote string) (bestBid, bestAsk float64, err error) {
+	book := c.book()
+	if best, ok := book.Best(Buy); ok {
+		bestBid = best.Price
+	}
+	if best, ok := book.Best(Sell); ok {
+		bestAsk = best.Price
+	}
+	return bestBid, bestAsk, nil
+}
+
+func (c *SimConnector) LastPrice(base, quote string) (float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dealIdx == 0 {
+		return 0, nil
+	}
+	return c.deals[c.dealIdx-1].Price, nil
+}
+
+func (c *SimConnector) Ticker(base, quote string) (*Ticker, error) {
+	bid, ask, _ := c.BestBidBestAsk(base, quote)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return TickerFromDeals(symbol(base, quote), c.deals[:c.dealIdx], bid, ask, c.now), nil
+}
+
+// DealHistory сделки, уже случившиеся по виртуальным часам, время в миллисекундах
+func (c *SimConnector) DealHistory(base, quote string, startTime, endTime int64) ([]*Level, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := make([]*Level, 0)
+	for _, deal := range c.deals[:c.dealIdx] {
+		if ms := deal.Time.UnixMilli(); ms >= startTime && ms <= endTime {
+			res = append(res, deal)
+		}
+	}
+	return res, nil
+}
+
+func (c *SimConnector) CurrencyBalance(currency string) (available, freeze float64, err error) {
+	available, freeze = c.engine.currencyBalance(currency)
+	return available, freeze, nil
+}
+
+// bookOrders уровни стакана в виде заявок, как их отдает OrderBook
+func bookOrders(book *OrderBookSnapshot, side Side, basePrecision, pricePrecision int) []*NetOrder {
+	levels := book.Levels(side)
+	res := make([]*NetOrder, 0, len(levels))
+	for _, level := range levels {
+		order, _ := NewNetOrder(&NetOrderConfig{
+			Symbol:     book.Symbol,
+			Side:       side,
+			OrderType:  Limit,
+			Price:      level.Price,
+			BaseAmount: level.Amount,
+			BasePrec:   basePrecision,
+			PricePrec:  pricePrecision,
+		})
+		res = append(res, order)
+	}
+	return res
+}
+
+func page(orders []*NetOrder, offset, limit int64) []*NetOrder {
+	if offset >= int64(len(orders)) {
+		return []*NetOrder{}
+	}
+	orders = orders[offset:]
+	if limit > 0 && int64(len(orders)) > limit {
+		orders = orders[:limit]
+	}
+	return orders
+}
@@ -1,9 +1,11 @@
 package exchange_models
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,6 +14,37 @@ type DivideConfig struct {
 	MinPartRatio   float64
 	MaxPartRatio   float64
 	PricePrecision int
+	Source         rand.Source // источник случайных чисел, не должен использоваться из нескольких горутин
+	Seed           int64       // используется, если Source не задан. При 0 зерно выбирается случайно
+}
+
+type SplitConfig struct {
+	PartsAmount int
+	Precision   int
+	Source      rand.Source
+	Seed        int64
+
+	// ограничения для SplitConstrained
+	MinPart     float64   // минимальная часть в base
+	MaxPart     float64   // максимальная часть в base, 0 - без ограничения
+	MinNotional float64   // минимальная часть в quote по цене из Prices
+	Prices      []float64 // цена каждой части, нужна при MinNotional
+}
+
+var ErrInfeasibleSplit = errors.New("amount can not be split with given constraints")
+
+var seedCounter atomic.Uint64
+
+// newRand генератор из source или seed. Без них каждый вызов получает свое зерно,
+// поэтому одновременные вызовы не повторяют друг друга
+func newRand(source rand.Source, seed int64) *rand.Rand {
+	if source != nil {
+		return rand.New(source)
+	}
+	if seed == 0 {
+		seed = int64(mix64(uint64(time.Now().UnixNano()) + seedCounter.Add(1)))
+	}
+	return rand.New(rand.NewSource(seed))
 }
 
 type Spread struct {
@@ -27,11 +60,113 @@ func (s *Spread) Print() {
 	fmt.Printf("----\n%f\n\n%f\n----\n", s.TopPrice, s.BottomPrice)
 }
 
+// SpreadFromBestBidAsk спред между лучшей покупкой и лучшей продажей в стакане
+func SpreadFromBestBidAsk(c Connector, base, quote string) (*Spread, error) {
+	bestBid, bestAsk, err := c.BestBidBestAsk(base, quote)
+	if err != nil {
+		return nil, err
+	}
+	if !validBookPrice(bestBid) || !validBookPrice(bestAsk) {
+		return nil, fmt.Errorf("order book %s is empty on one side: bid %f, ask %f", symbol(base, quote), bestBid, bestAsk)
+	}
+	return &Spread{TopPrice: bestAsk, BottomPrice: bestBid}, nil
+}
+
+// validBookPrice цена лучшего уровня, а не 0, бесконечность или math.MaxFloat64
+// вместо пустой стороны стакана
+func validBookPrice(price float64) bool {
+	return price > 0 && price < math.MaxFloat64 && !math.IsNaN(price)
+}
+
+// SpreadFromNet спред между лучшими ордерами сети, false если одна из сторон пуста
+func SpreadFromNet(net *ClassicNet) (*Spread, bool) {
+	net.mu.RLock()
+	defer net.mu.RUnlock()
+	bestBuy, bestSell := net.buy.first(), net.sell.first()
+	if bestBuy == nil || bestSell == nil {
+		return nil, false
+	}
+	return &Spread{TopPrice: bestSell.Price(), BottomPrice: bestBuy.Price()}, true
+}
+
+func (s *Spread) Mid() float64 {
+	return (s.TopPrice + s.BottomPrice) / 2
+}
+
+// WidthBps длина спреда относительно середины в базисных пунктах
+func (s *Spread) WidthBps() float64 {
+	mid := s.Mid()
+	if mid == 0 {
+		return 0
+	}
+	return s.Length() / mid * 10000
+}
+
+func (s *Spread) Contains(price float64) bool {
+	return price >= s.BottomPrice && price <= s.TopPrice
+}
+
+// Intersect общая часть спредов, false если они не пересекаются
+func (s *Spread) Intersect(other *Spread) (*Spread, bool) {
+	res := &Spread{TopPrice: math.Min(s.TopPrice, other.TopPrice), BottomPrice: math.Max(s.BottomPrice, other.BottomPrice)}
+	if res.Length() < 0 {
+		return nil, false
+	}
+	return res, true
+}
+
+// Union объединение спредов, false если между ними есть разрыв
+func (s *Spread) Union(other *Spread) (*Spread, bool) {
+	if _, ok := s.Intersect(other); !ok {
+		return nil, false
+	}
+	return &Spread{TopPrice: math.Max(s.TopPrice, other.TopPrice), BottomPrice: math.Min(s.BottomPrice, other.BottomPrice)}, true
+}
+
+// Subtract части спреда, не попавшие в other: ни одной, одна или две, сверху вниз
+func (s *Spread) Subtract(other *Spread) []*Spread {
+	common, ok := s.Intersect(other)
+	if !ok {
+		return []*Spread{{TopPrice: s.TopPrice, BottomPrice: s.BottomPrice}}
+	}
+	res := make([]*Spread, 0, 2)
+	if common.TopPrice < s.TopPrice {
+		res = append(res, &Spread{TopPrice: s.TopPrice, BottomPrice: common.TopPrice})
+	}
+	if common.BottomPrice > s.BottomPrice {
+		res = append(res, &Spread{TopPrice: common.BottomPrice, BottomPrice: s.BottomPrice})
+	}
+	return res
+}
+
+func (s *Spread) Shift(delta float64) *Spread {
+	return &Spread{TopPrice: s.TopPrice + delta, BottomPrice: s.BottomPrice + delta}
+}
+
+// Scale растягивает спред в factor раз относительно середины
+func (s *Spread) Scale(factor float64) *Spread {
+	mid, half := s.Mid(), s.Length()/2*factor
+	return &Spread{TopPrice: mid + half, BottomPrice: mid - half}
+}
+
+// SplitAt делит спред ценой price на верхнюю и нижнюю части, false если price вне спреда
+func (s *Spread) SplitAt(price float64) (top, bottom *Spread, ok bool) {
+	if !s.Contains(price) {
+		return nil, nil, false
+	}
+	return &Spread{TopPrice: s.TopPrice, BottomPrice: price}, &Spread{TopPrice: price, BottomPrice: s.BottomPrice}, true
+}
+
+// Snap округляет границы до точности цены
+func (s *Spread) Snap(pricePrecision int) *Spread {
+	return &Spread{TopPrice: Round(s.TopPrice, pricePrecision), BottomPrice: Round(s.BottomPrice, pricePrecision)}
+}
+
 // Divide делит весь спред на непересекающиеся отрезки
 func Divide(config *DivideConfig, baseSpread *Spread) []*Spread {
 	parts := make([]*Spread, 0, config.PartsAmount)
 	var ratiosSum float64
-	r := rand.New(rand.NewSource(time.Now().Unix()))
+	r := newRand(config.Source, config.Seed)
 	bottomPrice := Round(baseSpread.BottomPrice, config.PricePrecision) + math.Pow10(-config.PricePrecision)
 	topPrice := Round(baseSpread.TopPrice, config.PricePrecision) - math.Pow10(-config.PricePrecision)
 	currentTopPrice := topPrice
@@ -49,7 +184,13 @@ func Divide(config *DivideConfig, baseSpread *Spread) []*Spread {
 }
 
 func SplitAmount(amount float64, n int, precision int) []float64 {
-	r := rand.New(rand.NewSource(time.Now().Unix()))
+	return Split(amount, &SplitConfig{PartsAmount: n, Precision: precision})
+}
+
+// Split делит amount на config.PartsAmount случайных частей
+func Split(amount float64, config *SplitConfig) []float64 {
+	n, precision := config.PartsAmount, config.Precision
+	r := newRand(config.Source, config.Seed)
 	amount = Round(amount, precision)
 	if n == 1 {
 		return []float64{Round(amount, precision)}
@@ -79,7 +220,104 @@ func SplitAmount(amount float64, n int, precision int) []float64 {
 	return individualAmounts
 }
 
+// SplitConstrained делит amount на случайные части, каждая из которых лежит в [MinPart, MaxPart]
+// и не меньше MinNotional по своей цене. Сумма частей равна amount, округленному до Precision
+func SplitConstrained(amount float64, config *SplitConfig) ([]float64, error) {
+	n := config.PartsAmount
+	if n <= 0 {
+		return nil, fmt.Errorf("parts amount must be positive, got %d", n)
+	}
+	if config.MinNotional > 0 && len(config.Prices) != n {
+		return nil, fmt.Errorf("min notional needs %d prices, got %d", n, len(config.Prices))
+	}
+	step := math.Pow10(config.Precision)
+	total := int64(math.Round(amount * step))
+	low := make([]int64, n)
+	high := make([]int64, n)
+	var lowSum, highSum int64
+	for i := range low {
+		low[i] = max(1, ceilUnits(config.MinPart*step))
+		if config.MinNotional > 0 {
+			if config.Prices[i] <= 0 {
+				return nil, fmt.Errorf("price of part %d must be positive, got %f", i, config.Prices[i])
+			}
+			low[i] = max(low[i], ceilUnits(config.MinNotional/config.Prices[i]*step))
+		}
+		high[i] = total
+		if config.MaxPart > 0 {
+			high[i] = min(total, floorUnits(config.MaxPart*step))
+		}
+		if low[i] > high[i] {
+			return nil, fmt.Errorf("%w: part %d must be between %f and %f", ErrInfeasibleSplit, i, float64(low[i])/step, float64(high[i])/step)
+		}
+		lowSum += low[i]
+		highSum += high[i]
+	}
+	if lowSum > total || highSum < total {
+		return nil, fmt.Errorf("%w: %d parts of %f sum to between %f and %f", ErrInfeasibleSplit, n,
+			Round(amount, config.Precision), float64(lowSum)/step, float64(highSum)/step)
+	}
+
+	r := newRand(config.Source, config.Seed)
+	parts := low
+	rest := total - lowSum
+	weights := make([]float64, n)
+	for rest > 0 {
+		var weightsSum float64
+		for i := range weights {
+			weights[i] = 0
+			if parts[i] < high[i] {
+				weights[i] = r.Float64()
+				weightsSum += weights[i]
+			}
+		}
+		var given int64
+		for i, w := range weights {
+			if w == 0 {
+				continue
+			}
+			add := min(int64(float64(rest)*w/weightsSum), high[i]-parts[i], rest-given)
+			parts[i] += add
+			given += add
+		}
+		if given == 0 {
+			// остаток меньше числа частей, раздаем по одному шагу
+			for _, i := range r.Perm(n) {
+				if parts[i] < high[i] {
+					parts[i]++
+					given = 1
+					break
+				}
+			}
+		}
+		rest -= given
+	}
+
+	res := make([]float64, n)
+	for i, part := range parts {
+		res[i] = Round(float64(part)/step, config.Precision)
+	}
+	return res, nil
+}
+
+// ceilUnits и floorUnits округляют число шагов точности, не давая ошибке умножения
+// float64 добавить или отнять лишний шаг
+func ceilUnits(x float64) int64 {
+	return int64(math.Ceil(x - 1e-9*max(1, math.Abs(x))))
+}
+
+func floorUnits(x float64) int64 {
+	return int64(math.Floor(x + 1e-9*max(1, math.Abs(x))))
+}
+
 func RandomizeSpreadParts(slice []*Spread) []*Spread {
+	return RandomizeSpreadPartsWithSource(slice, nil)
+}
+
+// RandomizeSpreadPartsWithSource перемешивает части спреда, используя source.
+// При nil source берется новое случайное зерно
+func RandomizeSpreadPartsWithSource(slice []*Spread, source rand.Source) []*Spread {
+	r := newRand(source, 0)
 	// Create a new slice of the same length as the original slice
 	randomSlice := make([]*Spread, len(slice))
 	// Copy the content of the original slice to the new slice
@@ -87,7 +325,7 @@ func RandomizeSpreadParts(slice []*Spread) []*Spread {
 
 	// Shuffle the new slice randomly using Fisher-Yates algorithm
 	for i := len(randomSlice) - 1; i > 0; i-- {
-		j := rand.Intn(i + 1)
+		j := r.Intn(i + 1)
 		randomSlice[i], randomSlice[j] = randomSlice[j], randomSlice[i]
 	}
 
@@ -2,7 +2,11 @@ package exchange_models
 
 import (
 	"fmt"
+	"math"
+	"math/rand"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 var (
@@ -40,3 +44,166 @@ func TestSplitAmount(t *testing.T) {
 	}
 	fmt.Println(sum)
 }
+
+func TestDivide_Seed(t *testing.T) {
+	seeded := *config
+	seeded.Seed = 42
+	first, second := Divide(&seeded, spread), Divide(&seeded, spread)
+	for idx := range first {
+		assert.Equal(t, *first[idx], *second[idx])
+	}
+
+	splitConfig := &SplitConfig{PartsAmount: 4, Precision: 3, Seed: 7}
+	assert.Equal(t, Split(5.834, splitConfig), Split(5.834, splitConfig))
+
+	// общий источник продолжает последовательность, результаты воспроизводимы целиком
+	splitConfig = &SplitConfig{PartsAmount: 4, Precision: 3, Source: rand.NewSource(7)}
+	a, b := Split(5.834, splitConfig), Split(5.834, splitConfig)
+	splitConfig.Source = rand.NewSource(7)
+	assert.Equal(t, a, Split(5.834, splitConfig))
+	assert.Equal(t, b, Split(5.834, splitConfig))
+
+	parts := RandomizeSpreadPartsWithSource(first, rand.NewSource(1))
+	assert.Equal(t, parts, RandomizeSpreadPartsWithSource(first, rand.NewSource(1)))
+}
+
+func TestSplitConstrained(t *testing.T) {
+	splitConfig := &SplitConfig{
+		PartsAmount: 5,
+		Precision:   3,
+		Seed:        3,
+		MinPart:     0.5,
+		MaxPart:     2,
+		MinNotional: 30,
+		Prices:      []float64{54, 53, 52, 51, 50},
+	}
+	for i := 0; i < 100; i++ {
+		splitConfig.Seed = int64(i + 1)
+		parts, err := SplitConstrained(5.834, splitConfig)
+		assert.NoError(t, err)
+		var sum float64
+		for idx, part := range parts {
+			assert.GreaterOrEqual(t, part, 0.5)
+			assert.LessOrEqual(t, part, 2.0)
+			assert.GreaterOrEqual(t, part*splitConfig.Prices[idx], 30.0)
+			assert.Equal(t, Round(part, 3), part)
+			sum += part
+		}
+		assert.Equal(t, 5.834, Round(sum, 3))
+	}
+
+	_, err := SplitConstrained(11, splitConfig)
+	assert.ErrorIs(t, err, ErrInfeasibleSplit)
+	_, err = SplitConstrained(2.8, splitConfig)
+	assert.ErrorIs(t, err, ErrInfeasibleSplit)
+	splitConfig.MinNotional = 200
+	_, err = SplitConstrained(5.834, splitConfig)
+	assert.ErrorIs(t, err, ErrInfeasibleSplit)
+
+	// границы не округляются до ближайшего шага
+	boundaries := []struct {
+		name   string
+		amount float64
+		config *SplitConfig
+		want   []float64
+	}{
+		{"min notional just above amount", 0.555, &SplitConfig{PartsAmount: 1, Precision: 3, MinNotional: 30, Prices: []float64{54.05}}, nil},
+		{"min notional reached", 0.556, &SplitConfig{PartsAmount: 1, Precision: 3, MinNotional: 30, Prices: []float64{54.05}}, []float64{0.556}},
+		{"max part just below amount", 2, &SplitConfig{PartsAmount: 1, Precision: 3, MaxPart: 1.9996}, nil},
+		{"max part equal to amount", 1.999, &SplitConfig{PartsAmount: 1, Precision: 3, MaxPart: 1.9996}, []float64{1.999}},
+		{"min part exact", 0.3, &SplitConfig{PartsAmount: 3, Precision: 1, MinPart: 0.1}, []float64{0.1, 0.1, 0.1}},
+		{"min part just above step", 0.3, &SplitConfig{PartsAmount: 3, Precision: 1, MinPart: 0.1004}, nil},
+	}
+	for _, tt := range boundaries {
+		t.Run(tt.name, func(t *testing.T) {
+			parts, err := SplitConstrained(tt.amount, tt.config)
+			if tt.want == nil {
+				assert.ErrorIs(t, err, ErrInfeasibleSplit, "parts: %v", parts)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, parts)
+		})
+	}
+}
+
+func TestSpread_Algebra(t *testing.T) {
+	s := &Spread{TopPrice: 60, BottomPrice: 50}
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"mid", s.Mid(), 55.0},
+		{"width bps", Round(s.WidthBps(), 2), 1818.18},
+		{"contains", s.Contains(50), true},
+		{"not contains", s.Contains(60.01), false},
+		{"shift", s.Shift(-5), &Spread{TopPrice: 55, BottomPrice: 45}},
+		{"scale", s.Scale(0.5), &Spread{TopPrice: 57.5, BottomPrice: 52.5}},
+		{"snap", (&Spread{TopPrice: 60.126, BottomPrice: 49.994}).Snap(2), &Spread{TopPrice: 60.13, BottomPrice: 49.99}},
+		{"subtract middle", s.Subtract(&Spread{TopPrice: 58, BottomPrice: 52}), []*Spread{{TopPrice: 60, BottomPrice: 58}, {TopPrice: 52, BottomPrice: 50}}},
+		{"subtract top", s.Subtract(&Spread{TopPrice: 70, BottomPrice: 55}), []*Spread{{TopPrice: 55, BottomPrice: 50}}},
+		{"subtract all", s.Subtract(&Spread{TopPrice: 70, BottomPrice: 40}), []*Spread{}},
+		{"subtract disjoint", s.Subtract(&Spread{TopPrice: 70, BottomPrice: 65}), []*Spread{{TopPrice: 60, BottomPrice: 50}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.got)
+		})
+	}
+
+	setTests := []struct {
+		name         string
+		other        *Spread
+		intersect    *Spread
+		union        *Spread
+		intersectsOk bool
+		unionOk      bool
+	}{
+		{"overlap", &Spread{TopPrice: 65, BottomPrice: 55}, &Spread{TopPrice: 60, BottomPrice: 55}, &Spread{TopPrice: 65, BottomPrice: 50}, true, true},
+		{"inside", &Spread{TopPrice: 58, BottomPrice: 52}, &Spread{TopPrice: 58, BottomPrice: 52}, &Spread{TopPrice: 60, BottomPrice: 50}, true, true},
+		{"touch", &Spread{TopPrice: 70, BottomPrice: 60}, &Spread{TopPrice: 60, BottomPrice: 60}, &Spread{TopPrice: 70, BottomPrice: 50}, true, true},
+		{"disjoint", &Spread{TopPrice: 45, BottomPrice: 40}, nil, nil, false, false},
+	}
+	for _, tt := range setTests {
+		t.Run(tt.name, func(t *testing.T) {
+			intersect, ok := s.Intersect(tt.other)
+			assert.Equal(t, tt.intersectsOk, ok)
+			assert.Equal(t, tt.intersect, intersect)
+			union, ok := s.Union(tt.other)
+			assert.Equal(t, tt.unionOk, ok)
+			assert.Equal(t, tt.union, union)
+		})
+	}
+
+	top, bottom, ok := s.SplitAt(52)
+	assert.True(t, ok)
+	assert.Equal(t, &Spread{TopPrice: 60, BottomPrice: 52}, top)
+	assert.Equal(t, &Spread{TopPrice: 52, BottomPrice: 50}, bottom)
+	_, _, ok = s.SplitAt(49)
+	assert.False(t, ok)
+}
+
+func TestSpread_From(t *testing.T) {
+	c := newMockConnector()
+	_, err := SpreadFromBestBidAsk(c, "SDFA", "USDT")
+	assert.Error(t, err)
+	c.bestBid, c.bestAsk = 54.5, math.MaxFloat64
+	_, err = SpreadFromBestBidAsk(c, "SDFA", "USDT")
+	assert.Error(t, err)
+	c.bestAsk = math.Inf(1)
+	_, err = SpreadFromBestBidAsk(c, "SDFA", "USDT")
+	assert.Error(t, err)
+	c.bestBid, c.bestAsk = 54.5, 55.5
+	s, err := SpreadFromBestBidAsk(c, "SDFA", "USDT")
+	assert.NoError(t, err)
+	assert.Equal(t, &Spread{TopPrice: 55.5, BottomPrice: 54.5}, s)
+
+	net := newTestNet(newTestOrder("1", Buy, 54, 1, 0))
+	_, ok := SpreadFromNet(net)
+	assert.False(t, ok)
+	net.InsertOrder(newTestOrder("2", Sell, 56, 1, 0))
+	s, ok = SpreadFromNet(net)
+	assert.True(t, ok)
+	assert.Equal(t, 55.0, s.Mid())
+}
@@ -0,0 +1,246 @@
+package exchange_models
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultOrdersPollInterval = 2 * time.Second
+
+// BookUpdate снимок или изменение стакана. Bids заполняют BuyAmount, Asks - SellAmount,
+// нулевой объем означает, что уровень исчез из стакана
+type BookUpdate struct {
+	Symbol   string
+	Snapshot bool
+	Sequence int64
+	Bids     []*Level
+	Asks     []*Level
+	Time     time.Time
+}
+
+type Trade struct {
+	ID     string
+	Symbol string
+	Side   Side
+	Price  float64
+	Amount float64
+	Time   time.Time
+}
+
+// Level сделка в том же виде, в котором ее отдает DealHistory
+func (t *Trade) Level() *Level {
+	level := &Level{
+		Price: t.Price,
+		ID:    t.ID,
+		Time:  t.Time,
+	}
+	if t.Side == Sell {
+		level.SellAmount = t.Amount
+	} else {
+		level.BuyAmount = t.Amount
+	}
+	return level
+}
+
+type StreamingConnector interface {
+	Connector
+	SubscribeOrderBook(base, quote string) (<-chan *BookUpdate, error)
+	SubscribeTrades(base, quote string) (<-chan *Trade, error)
+	SubscribeOrders(base, quote string, basePrecision, pricePrecision int) (<-chan *NetOrder, error)
+	Errors() <-chan error
+	Close() error
+}
+
+// streamHub раздает события подписчикам, по одному каналу на символ и тип данных
+type streamHub struct {
+	mu        sync.Mutex
+	books     map[string]chan *BookUpdate
+	sequences map[string]int64
+	trades    map[string]chan *Trade
+	orders    map[string]chan *NetOrder
+	errs      chan error
+	closed    bool
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func newStreamHub() *streamHub {
+	return &streamHub{
+		books:     make(map[string]chan *BookUpdate),
+		sequences: make(map[string]int64),
+		trades:    make(map[string]chan *Trade),
+		orders:    make(map[string]chan *NetOrder),
+		errs:      make(chan error, 16),
+		done:      make(chan struct{}),
+	}
+}
+
+func (h *streamHub) addBook(symbol string) (chan *BookUpdate, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrStreamClosed
+	}
+	if _, ok := h.books[symbol]; ok {
+		return nil, fmt.Errorf("order book of %s is already subscribed", symbol)
+	}
+	ch := make(chan *BookUpdate, 64)
+	h.books[symbol] = ch
+	return ch, nil
+}
+
+func (h *streamHub) addTrades(symbol string) (chan *Trade, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrStreamClosed
+	}
+	if _, ok := h.trades[symbol]; ok {
+		return nil, fmt.Errorf("trades of %s are already subscribed", symbol)
+	}
+	ch := make(chan *Trade, 64)
+	h.trades[symbol] = ch
+	return ch, nil
+}
+
+// nextSequence используется биржами, которые не нумеруют обновления стакана сами
+func (h *streamHub) nextSequence(symbol string) int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sequences[symbol]++
+	return h.sequences[symbol]
+}
+
+// skipSequences пропускает по номеру у всех стаканов с локальной нумерацией.
+// Обновления, потерянные при обрыве соединения, так не отследить, а разрыв
+// в sequence заставит LocalOrderBook загрузить стакан заново
+func (h *streamHub) skipSequences() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for symbol := range h.sequences {
+		h.sequences[symbol]++
+	}
+}
+
+func (h *streamHub) publishBook(update *BookUpdate) {
+	h.mu.Lock()
+	ch, ok := h.books[update.Symbol]
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case ch <- update:
+	case <-h.done:
+	}
+}
+
+func (h *streamHub) publishTrade(trade *Trade) {
+	h.mu.Lock()
+	ch, ok := h.trades[trade.Symbol]
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case ch <- trade:
+	case <-h.done:
+	}
+}
+
+func (h *streamHub) reportError(err error) {
+	select {
+	case h.errs <- err:
+	default:
+	}
+}
+
+// pollOrders отдает изменения наших ордеров, опрашивая AllOpenOrders.
+// Ордер, пропавший из открытых, отдается со статусом Closed и датой смерти
+func (h *streamHub) pollOrders(c Connector, base, quote string, basePrecision, pricePrecision int, interval time.Duration) (<-chan *NetOrder, error) {
+	symbol := symbol(base, quote)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrStreamClosed
+	}
+	if _, ok := h.orders[symbol]; ok {
+		return nil, fmt.Errorf("orders of %s are already subscribed", symbol)
+	}
+	ch := make(chan *NetOrder, 64)
+	h.orders[symbol] = ch
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		defer close(ch)
+		known := make(map[string]*NetOrder)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			orders, err := c.AllOpenOrders(base, quote, basePrecision, pricePrecision)
+			if err != nil {
+				h.reportError(err)
+			} else {
+				for _, order := range diffOpenOrders(known, orders) {
+					select {
+					case ch <- order:
+					case <-h.done:
+						return
+					}
+				}
+			}
+			select {
+			case <-h.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// diffOpenOrders обновляет known и возвращает новые, изменившиеся и пропавшие ордера.
+// Наружу уходят копии, known подписчику не отдается
+func diffOpenOrders(known map[string]*NetOrder, live []*NetOrder) []*NetOrder {
+	changed := make([]*NetOrder, 0)
+	seen := make(map[string]bool, len(live))
+	for _, order := range live {
+		seen[order.ID()] = true
+		prev, ok := known[order.ID()]
+		if !ok || prev.FilledAmount() != order.FilledAmount() || prev.Status() != order.Status() {
+			changed = append(changed, order.clone())
+		}
+		known[order.ID()] = order.clone()
+	}
+	for id, order := range known {
+		if seen[id] {
+			continue
+		}
+		changed = append(changed, order.clone().SetStatus(Closed).SetDeathDate(time.Now().UTC()))
+		delete(known, id)
+	}
+	return changed
+}
+
+// close останавливает опросы, затем источники событий stopSources и только потом закрывает каналы
+func (h *streamHub) close(stopSources func()) {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return
+	}
+	h.closed = true
+	close(h.done)
+	h.mu.Unlock()
+	h.wg.Wait()
+	stopSources()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, ch := range h.books {
+		close(ch)
+	}
+	for _, ch := range h.trades {
+		close(ch)
+	}
+}
@@ -0,0 +1,189 @@
+package exchange_models
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// wsTestServer отвечает на каждое входящее сообщение сообщениями из reply,
+// после maxMessages ответов рвет соединение
+type wsTestServer struct {
+	*httptest.Server
+	received chan string
+}
+
+func newWsTestServer(t *testing.T, maxMessages int, reply func(msg string) []string) *wsTestServer {
+	s := &wsTestServer{received: make(chan string, 64)}
+	upgrader := websocket.Upgrader{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		sent := 0
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			s.received <- string(msg)
+			for _, m := range reply(string(msg)) {
+				if err := conn.WriteMessage(websocket.TextMessage, []byte(m)); err != nil {
+					return
+				}
+				sent++
+			}
+			if maxMessages > 0 && sent >= maxMessages {
+				return
+			}
+		}
+	}))
+	return s
+}
+
+func (s *wsTestServer) wsURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+func TestP2BStreamingConnector_OrderBookResubscribes(t *testing.T) {
+	server := newWsTestServer(t, 2, func(msg string) []string {
+		if !strings.Contains(msg, "depth.subscribe") {
+			return nil
+		}
+		return []string{
+			`{"method":"depth.update","params":[true,{"asks":[["55.10","1.5"]],"bids":[["54.90","2"]]},"SDFA_USDT"],"id":null}`,
+			`{"method":"depth.update","params":[false,{"bids":[["54.90","0"]]},"SDFA_USDT"],"id":null}`,
+		}
+	})
+	defer server.Close()
+
+	c := &P2BStreamingConnector{
+		P2BConnector:       &P2BConnector{},
+		URL:                server.wsURL(),
+		OrdersPollInterval: defaultOrdersPollInterval,
+		hub:                newStreamHub(),
+	}
+	updates, err := c.SubscribeOrderBook("SDFA", "USDT")
+	assert.NoError(t, err)
+	_, err = c.SubscribeOrderBook("SDFA", "USDT")
+	assert.Error(t, err)
+
+	first := receiveBookUpdate(t, updates)
+	assert.True(t, first.Snapshot)
+	assert.Equal(t, "SDFA_USDT", first.Symbol)
+	assert.Equal(t, 54.90, first.Bids[0].Price)
+	assert.Equal(t, 2.0, first.Bids[0].BuyAmount)
+	assert.Equal(t, 1.5, first.Asks[0].SellAmount)
+
+	delta := receiveBookUpdate(t, updates)
+	assert.False(t, delta.Snapshot)
+	assert.Equal(t, first.Sequence+1, delta.Sequence)
+	assert.Equal(t, 0.0, delta.Bids[0].BuyAmount)
+
+	// сервер рвет соединение, после переподключения подписка отправляется заново
+	resync := receiveBookUpdate(t, updates)
+	assert.True(t, resync.Snapshot)
+	// сервер не нумерует обновления, после переподключения номер пропускается
+	assert.Equal(t, delta.Sequence+2, resync.Sequence)
+	assert.Contains(t, <-server.received, "depth.subscribe")
+	assert.Contains(t, <-server.received, "depth.subscribe")
+
+	assert.NoError(t, c.Close())
+	for range updates {
+	}
+}
+
+func TestAzBitStreamingConnector_Trades(t *testing.T) {
+	server := newWsTestServer(t, 0, func(msg string) []string {
+		var req azBitWsRequest
+		if err := json.Unmarshal([]byte(msg), &req); err != nil || req.Channel != "deals" {
+			return nil
+		}
+		return []string{
+			`{"channel":"deals","currencyPairCode":"SDFA_USDT","data":[` +
+				`{"id":"a1","dealDateUtc":"2024-06-20T13:12:57","price":55.1,"volume":0.3,"isBuy":true},` +
+				`{"id":"a2","dealDateUtc":"2024-06-20T13:12:58","price":55.0,"volume":0.1,"isBuy":false}]}`,
+		}
+	})
+	defer server.Close()
+
+	c := &AzBitStreamingConnector{
+		AzBitConnector:     &AzBitConnector{},
+		URL:                server.wsURL(),
+		OrdersPollInterval: defaultOrdersPollInterval,
+		hub:                newStreamHub(),
+	}
+	trades, err := c.SubscribeTrades("SDFA", "USDT")
+	assert.NoError(t, err)
+
+	first := receiveTrade(t, trades)
+	assert.Equal(t, "a1", first.ID)
+	assert.Equal(t, Buy, first.Side)
+	assert.Equal(t, 0.3, first.Amount)
+	assert.Equal(t, time.Date(2024, 6, 20, 13, 12, 57, 0, time.UTC), first.Time)
+	second := receiveTrade(t, trades)
+	assert.Equal(t, Sell, second.Side)
+
+	assert.NoError(t, c.Close())
+	_, err = c.SubscribeTrades("SDFA", "BTC")
+	assert.ErrorIs(t, err, ErrStreamClosed)
+}
+
+func TestDiffOpenOrders(t *testing.T) {
+	newOrder := func(id string, filled float64) *NetOrder {
+		order, _ := NewNetOrder(&NetOrderConfig{Id: id, Side: Buy, Price: 10, BaseAmount: 2, FilledAmount: filled, Status: New})
+		return order
+	}
+	known := make(map[string]*NetOrder)
+	changed := diffOpenOrders(known, []*NetOrder{newOrder("1", 0), newOrder("2", 0)})
+	assert.Len(t, changed, 2)
+	delivered := changed
+
+	changed = diffOpenOrders(known, []*NetOrder{newOrder("1", 0), newOrder("2", 0)})
+	assert.Len(t, changed, 0)
+
+	changed = diffOpenOrders(known, []*NetOrder{newOrder("2", 1)})
+	assert.Len(t, changed, 2)
+	for _, order := range changed {
+		if order.ID() == "1" {
+			assert.Equal(t, Closed, order.Status())
+			assert.False(t, order.DeathDate().IsZero())
+		} else {
+			assert.Equal(t, 1.0, order.FilledAmount())
+		}
+	}
+	// отданные раньше ордера не меняются
+	for _, order := range delivered {
+		assert.Equal(t, New, order.Status())
+		assert.True(t, order.DeathDate().IsZero())
+	}
+	assert.Len(t, known, 1)
+}
+
+func receiveBookUpdate(t *testing.T, ch <-chan *BookUpdate) *BookUpdate {
+	select {
+	case u := <-ch:
+		return u
+	case <-time.After(5 * time.Second):
+		t.Fatal("no book update received")
+	}
+	return nil
+}
+
+func receiveTrade(t *testing.T, ch <-chan *Trade) *Trade {
+	select {
+	case tr := <-ch:
+		return tr
+	case <-time.After(5 * time.Second):
+		t.Fatal("no trade received")
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package exchange_models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const tickerWindow = 24 * time.Hour
+
+// Ticker статистика пары за последние 24 часа
+type Ticker struct {
+	Symbol        string
+	Last          float64
+	Bid           float64
+	Ask           float64
+	Open          float64
+	High          float64
+	Low           float64
+	BaseVolume    float64
+	QuoteVolume   float64
+	ChangePercent float64
+	Time          time.Time
+}
+
+func (t *Ticker) Print() {
+	fmt.Printf("%s, last: %f, bid: %f, ask: %f, open: %f, high: %f, low: %f, base volume: %f, quote volume: %f, change: %.2f%%\n",
+		t.Symbol,
+		t.Last,
+		t.Bid,
+		t.Ask,
+		t.Open,
+		t.High,
+		t.Low,
+		t.BaseVolume,
+		t.QuoteVolume,
+		t.ChangePercent)
+}
+
+// TickerFromDeals считает статистику по сделкам за 24 часа до now
+func TickerFromDeals(symbol string, deals []*Level, bid, ask float64, now time.Time) *Ticker {
+	ticker := &Ticker{
+		Symbol: symbol,
+		Bid:    bid,
+		Ask:    ask,
+		Time:   now,
+	}
+	from := now.Add(-tickerWindow)
+	window := make([]*Level, 0, len(deals))
+	for _, deal := range deals {
+		if deal.Time.Before(from) || deal.Time.After(now) {
+			continue
+		}
+		window = append(window, deal)
+	}
+	if len(window) == 0 {
+		return ticker
+	}
+	sort.SliceStable(window, func(i, j int) bool {
+		return window[i].Time.Before(window[j].Time)
+	})
+	ticker.Open = window[0].Price
+	ticker.Last = window[len(window)-1].Price
+	ticker.High = window[0].Price
+	ticker.Low = window[0].Price
+	for _, deal := range window {
+		if deal.Price > ticker.High {
+			ticker.High = deal.Price
+		}
+		if deal.Price < ticker.Low {
+			ticker.Low = deal.Price
+		}
+		amount := deal.BuyAmount + deal.SellAmount
+		ticker.BaseVolume += amount
+		ticker.QuoteVolume += amount * deal.Price
+	}
+	if ticker.Open > 0 {
+		ticker.ChangePercent = (ticker.Last - ticker.Open) / ticker.Open * 100
+	}
+	return ticker
+}
+
+// TickerFromDealHistory запасной вариант для бирж без ticker эндпоинта:
+// статистика по DealHistory (время в миллисекундах) и лучшие цены из BestBidBestAsk.
+// Если за 24 часа сделок не было, Last берется из LastPrice
+func TickerFromDealHistory(c Connector, base, quote string) (*Ticker, error) {
+	now := time.Now().UTC()
+	deals, err := c.DealHistory(base, quote, now.Add(-tickerWindow).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	bid, ask, err := c.BestBidBestAsk(base, quote)
+	if err != nil {
+		return nil, err
+	}
+	ticker := TickerFromDeals(symbol(base, quote), deals, bid, ask, now)
+	if ticker.Last == 0 {
+		if ticker.Last, err = c.LastPrice(base, quote); err != nil {
+			return nil, err
+		}
+	}
+	return ticker, nil
+}
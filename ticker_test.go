@@ -0,0 +1,53 @@
+package exchange_models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTickerFromDeals(t *testing.T) {
+	now := time.Date(2024, 6, 20, 13, 0, 0, 0, time.UTC)
+	deals := []*Level{
+		{Price: 60, BuyAmount: 1, Time: now.Add(-25 * time.Hour)},
+		{Price: 52, SellAmount: 1, Time: now.Add(-time.Hour)},
+		{Price: 50, BuyAmount: 2, Time: now.Add(-23 * time.Hour)},
+		{Price: 55, BuyAmount: 1, Time: now.Add(-10 * time.Hour)},
+	}
+
+	ticker := TickerFromDeals("SDFA_USDT", deals, 51.9, 52.1, now)
+	assert.Equal(t, 50.0, ticker.Open)
+	assert.Equal(t, 52.0, ticker.Last)
+	assert.Equal(t, 55.0, ticker.High)
+	assert.Equal(t, 50.0, ticker.Low)
+	assert.Equal(t, 4.0, ticker.BaseVolume)
+	assert.Equal(t, 100.0+55+52, ticker.QuoteVolume)
+	assert.InDelta(t, 4.0, ticker.ChangePercent, 1e-9)
+	assert.Equal(t, 51.9, ticker.Bid)
+
+	empty := TickerFromDeals("SDFA_USDT", nil, 51.9, 52.1, now)
+	assert.Equal(t, 0.0, empty.Last)
+	assert.Equal(t, 52.1, empty.Ask)
+}
+
+func TestTickerFromDealHistory(t *testing.T) {
+	c := newMockConnector()
+	c.bestBid, c.bestAsk = 9.9, 10.1
+	c.deals = []*Level{{Price: 10, BuyAmount: 3, Time: time.Now().UTC().Add(-time.Minute)}}
+
+	ticker, err := TickerFromDealHistory(c, "SDFA", "USDT")
+	assert.NoError(t, err)
+	assert.Equal(t, "SDFA_USDT", ticker.Symbol)
+	assert.Equal(t, 10.0, ticker.Last)
+	assert.Equal(t, 3.0, ticker.BaseVolume)
+	assert.Equal(t, 9.9, ticker.Bid)
+
+	// сделок за 24 часа нет, Last берется из LastPrice
+	c.deals = nil
+	c.lastPrice = 10.5
+	ticker, err = TickerFromDealHistory(c, "SDFA", "USDT")
+	assert.NoError(t, err)
+	assert.Equal(t, 10.5, ticker.Last)
+	assert.Zero(t, ticker.BaseVolume)
+}
@@ -0,0 +1,191 @@
+package exchange_models
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsMinBackoff   = 500 * time.Millisecond
+	wsMaxBackoff   = 30 * time.Second
+	wsPingInterval = 15 * time.Second
+)
+
+var ErrStreamClosed = errors.New("stream is closed")
+
+// wsConn держит одно websocket соединение, переподключается при обрыве
+// и повторно отправляет все подписки после каждого подключения
+type wsConn struct {
+	url          string
+	pingInterval time.Duration
+	ping         func() []byte // nil означает ping фреймом websocket
+	handle       func(msg []byte)
+	onConnect    func() // после каждого подключения и отправки подписок, может быть nil
+	errs         chan<- error
+
+	mu      sync.Mutex
+	conn    *websocket.Conn
+	subs    [][]byte
+	started bool
+	closed  bool
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func newWsConn(url string, handle func(msg []byte), ping func() []byte, errs chan<- error) *wsConn {
+	return &wsConn{
+		url:          url,
+		pingInterval: wsPingInterval,
+		ping:         ping,
+		handle:       handle,
+		errs:         errs,
+		done:         make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+}
+
+// subscribe запоминает сообщение подписки и отправляет его, если соединение уже есть.
+// Первая подписка запускает соединение
+func (w *wsConn) subscribe(msg []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrStreamClosed
+	}
+	w.subs = append(w.subs, msg)
+	if !w.started {
+		w.started = true
+		go w.run()
+		return nil
+	}
+	if w.conn != nil {
+		return w.conn.WriteMessage(websocket.TextMessage, msg)
+	}
+	return nil
+}
+
+func (w *wsConn) run() {
+	defer close(w.stopped)
+	backoff := wsMinBackoff
+	for {
+		conn, _, err := websocket.DefaultDialer.Dial(w.url, nil)
+		if err == nil {
+			backoff = wsMinBackoff
+			err = w.serve(conn)
+		}
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+		w.reportError(err)
+		select {
+		case <-w.done:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > wsMaxBackoff {
+			backoff = wsMaxBackoff
+		}
+	}
+}
+
+func (w *wsConn) serve(conn *websocket.Conn) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return conn.Close()
+	}
+	for _, msg := range w.subs {
+		if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			w.mu.Unlock()
+			conn.Close()
+			return err
+		}
+	}
+	w.conn = conn
+	w.mu.Unlock()
+	if w.onConnect != nil {
+		w.onConnect()
+	}
+
+	stopPing := make(chan struct{})
+	defer func() {
+		close(stopPing)
+		w.mu.Lock()
+		w.conn = nil
+		w.mu.Unlock()
+		conn.Close()
+	}()
+
+	deadline := 2 * w.pingInterval
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(deadline))
+	})
+	go w.keepAlive(conn, stopPing)
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(deadline)); err != nil {
+			return err
+		}
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		w.handle(msg)
+	}
+}
+
+func (w *wsConn) keepAlive(conn *websocket.Conn, stop <-chan struct{}) {
+	ticker := time.NewTicker(w.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		w.mu.Lock()
+		var err error
+		if w.ping != nil {
+			err = conn.WriteMessage(websocket.TextMessage, w.ping())
+		} else {
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(w.pingInterval))
+		}
+		w.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (w *wsConn) reportError(err error) {
+	if err == nil || w.errs == nil {
+		return
+	}
+	select {
+	case w.errs <- err:
+	default:
+	}
+}
+
+func (w *wsConn) close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.done)
+	if w.conn != nil {
+		w.conn.Close()
+	}
+	started := w.started
+	w.mu.Unlock()
+	if started {
+		<-w.stopped
+	}
+}